@@ -0,0 +1,35 @@
+package ddns
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// bindInterface is used to bind the dialer to the network interface, the
+// interface is checked when dial, so the interface can be created later,
+// and the address assigned by DHCP is not need to be known in advance.
+func bindInterface(dialer *net.Dialer, name, network string) error {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return errors.Errorf("interface %s is not found", name)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return errors.Errorf("interface %s is down", name)
+	}
+	return bindDevice(dialer, iface, network)
+}
+
+// dialInterface returns the dial function that the connections are bound
+// to the network interface, it is used by the transport about provider.
+func dialInterface(name string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := net.Dialer{}
+		err := bindInterface(&dialer, name, network)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
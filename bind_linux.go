@@ -0,0 +1,30 @@
+//go:build linux
+
+package ddns
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// bindDevice will set SO_BINDTODEVICE to the socket before connect, so
+// the connection always uses the interface whatever the address is.
+func bindDevice(dialer *net.Dialer, iface *net.Interface, _ string) error {
+	name := iface.Name
+	dialer.Control = func(_, _ string, conn syscall.RawConn) error {
+		var err error
+		e := conn.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+		})
+		if e != nil {
+			return e
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to bind interface %s", name)
+		}
+		return nil
+	}
+	return nil
+}
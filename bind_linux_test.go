@@ -0,0 +1,40 @@
+//go:build linux
+
+package ddns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindDevice(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	var loopback string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("loopback interface is not found")
+	}
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	conn, err := dialInterface(loopback)(context.Background(), "tcp4", listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
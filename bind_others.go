@@ -0,0 +1,45 @@
+//go:build !linux
+
+package ddns
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bindDevice will use the current address on the interface as the local
+// address, the IPv4 address is preferred if the network has no family.
+func bindDevice(dialer *net.Dialer, iface *net.Interface, network string) error {
+	var ip net.IP
+	if !strings.HasSuffix(network, "6") {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.IsGlobalUnicast() && ipNet.IP.To4() != nil {
+				ip = ipNet.IP.To4()
+				break
+			}
+		}
+	}
+	if ip == nil && !strings.HasSuffix(network, "4") {
+		addrs, err := localIPv6Addrs(iface.Name)
+		if err != nil {
+			return err
+		}
+		ip = selectIPv6(addrs)
+	}
+	if ip == nil {
+		return errors.Errorf("no address on interface %s about %s", iface.Name, network)
+	}
+	if strings.HasPrefix(network, "udp") {
+		dialer.LocalAddr = &net.UDPAddr{IP: ip}
+	} else {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindInterface(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
+		dialer := net.Dialer{}
+		err := bindInterface(&dialer, "ddns-missing0", "tcp4")
+		require.EqualError(t, err, "interface ddns-missing0 is not found")
+	})
+
+	t.Run("dial", func(t *testing.T) {
+		dial := dialInterface("ddns-missing0")
+
+		conn, err := dial(context.Background(), "tcp4", "127.0.0.1:80")
+		require.EqualError(t, err, "interface ddns-missing0 is not found")
+		require.Nil(t, conn)
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		dial := dialProvider("ddns-missing0", "tcp6", false)
+
+		conn, err := dial(context.Background(), "tcp4", "127.0.0.1:80")
+		require.EqualError(t, err, "interface ddns-missing0 is not found")
+		require.Nil(t, conn)
+
+		// the family is forced when the connection is not proxied
+		conn, err = dialProvider("", "tcp6", false)(context.Background(), "tcp", "127.0.0.1:80")
+		require.Error(t, err)
+		require.Nil(t, conn)
+	})
+
+	t.Run("source", func(t *testing.T) {
+		cfg := SourceConfig{
+			URL:       "http://127.0.0.1/",
+			Interface: "ddns-missing0",
+		}
+		src, err := newHTTPSource(&cfg, true, defaultUDPSourceTimeout, nil)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.ErrorContains(t, err, "interface ddns-missing0 is not found")
+		require.Nil(t, ip)
+	})
+}
@@ -0,0 +1,98 @@
+package ddns
+
+import (
+	"net"
+)
+
+// actions when the IPv4 address is behind carrier-grade NAT.
+const cgnatIPv6Only = "ipv6_only"
+
+// bogonNetworks are the private, documentation and special-use networks
+// that are not reachable from internet.
+var bogonNetworks = parseCIDRs(
+	// IPv4
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	// IPv6
+	"::/128",
+	"::1/128",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// sharedNetwork is the shared address space for CGNAT (RFC 6598).
+var sharedNetwork = parseCIDRs("100.64.0.0/10")[0]
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	list := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		list[i] = ipNet
+	}
+	return list
+}
+
+// isBogon is used to check the address is a private or special-use
+// address that should not be published.
+func isBogon(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, ipNet := range bogonNetworks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalSource is used to check the source returns the address of the
+// gateway or local interface, it is used to compare with the public
+// address returned by other sources to detect CGNAT.
+func isLocalSource(src source) bool {
+	if cached, ok := src.(*cachedSource); ok {
+		src = cached.source
+	}
+	switch src.(type) {
+	case *upnpSource, *natpmpSource, *pcpSource, *interfaceSource:
+		return true
+	default:
+		return false
+	}
+}
+
+// isBehindCGNAT is used to check the address of the local source shows
+// the public address is behind CGNAT, the external address of gateway is
+// compared with the public address, and the address of interface is only
+// checked that it is in the shared address space.
+func isBehindCGNAT(src source, local, public net.IP) bool {
+	if cached, ok := src.(*cachedSource); ok {
+		src = cached.source
+	}
+	switch src.(type) {
+	case *upnpSource, *natpmpSource, *pcpSource:
+		return !local.Equal(public)
+	case *interfaceSource:
+		return sharedNetwork.Contains(local)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,214 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBogon(t *testing.T) {
+	for _, ip := range []string{
+		"10.0.0.1", "100.64.0.1", "127.0.0.1", "172.16.1.1", "192.168.1.1",
+		"::ffff:192.168.1.1", "fd00::1", "fe80::1", "::1",
+		"192.0.2.1", "198.51.100.1", "203.0.113.1", "2001:db8::1",
+	} {
+		require.True(t, isBogon(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{
+		"1.2.3.4", "100.128.0.1", "172.32.0.1", "2400:cb00::1", "2606:4700::1",
+	} {
+		require.False(t, isBogon(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestIsLocalSource(t *testing.T) {
+	require.True(t, isLocalSource(new(natpmpSource)))
+	require.True(t, isLocalSource(&cachedSource{source: new(upnpSource)}))
+	require.True(t, isLocalSource(new(interfaceSource)))
+	require.False(t, isLocalSource(new(httpSource)))
+	require.False(t, isLocalSource(&cachedSource{source: new(stunSource)}))
+}
+
+// testLocalSource returns an interface source with the fake address.
+func testLocalSource(ip string) *interfaceSource {
+	return &interfaceSource{
+		name: "eth0",
+		ipv4: true,
+		addrs: func(string) ([]net.Addr, error) {
+			return []net.Addr{&net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(24, 32)}}, nil
+		},
+	}
+}
+
+func TestUpdater_detect_Bogon(t *testing.T) {
+	logger := new(testLogger)
+	updater := Updater{clock: newTestClock(), logger: logger}
+	private := &testSource{
+		name: "http://ipv4.example.com/",
+		detect: func() (net.IP, error) {
+			return net.ParseIP("192.168.1.2"), nil
+		},
+	}
+	public := &testSource{
+		name: "stun",
+		detect: func() (net.IP, error) {
+			return net.ParseIP("1.2.3.4"), nil
+		},
+	}
+
+	t.Run("refuse", func(t *testing.T) {
+		d, err := updater.detect(context.Background(), []source{private}, "ipv4", addrPolicy{})
+		require.EqualError(t, err, "192.168.1.2 is a bogon address")
+		require.Equal(t, "192.168.1.2 is a bogon address", d.Error)
+	})
+
+	t.Run("documentation", func(t *testing.T) {
+		doc := &testSource{
+			name: "http://ipv6.example.com/",
+			detect: func() (net.IP, error) {
+				return net.ParseIP("2001:db8::1"), nil
+			},
+		}
+		d, err := updater.detect(context.Background(), []source{doc}, "ipv6", addrPolicy{})
+		require.EqualError(t, err, "2001:db8::1 is a bogon address")
+		require.Empty(t, d.IP)
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		policy := addrPolicy{allowBogon: true}
+		d, err := updater.detect(context.Background(), []source{private}, "ipv4", policy)
+		require.NoError(t, err)
+		require.Equal(t, "192.168.1.2", d.IP)
+		require.False(t, d.CGNAT)
+	})
+
+	t.Run("cgnat", func(t *testing.T) {
+		local := testLocalSource("100.64.1.2")
+
+		d, err := updater.detect(context.Background(), []source{local, public}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", d.IP)
+		require.True(t, d.CGNAT)
+		require.Equal(t, "100.64.1.2", d.LocalIP)
+		require.Contains(t, logger.Logs(), "[warning] ipv4 address is behind CGNAT, the local address is 100.64.1.2")
+	})
+
+	t.Run("no cgnat", func(t *testing.T) {
+		local := testLocalSource("1.2.3.4")
+
+		d, err := updater.detect(context.Background(), []source{local, public}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.Equal(t, "interface(eth0)", d.Source)
+		require.False(t, d.CGNAT)
+		require.Empty(t, d.LocalIP)
+	})
+
+	t.Run("lan interface", func(t *testing.T) {
+		local := testLocalSource("192.168.1.2")
+
+		d, err := updater.detect(context.Background(), []source{local, public}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", d.IP)
+		require.False(t, d.CGNAT)
+		require.Empty(t, d.LocalIP)
+	})
+
+	t.Run("local source after public", func(t *testing.T) {
+		local := testLocalSource("100.64.1.3")
+
+		d, err := updater.detect(context.Background(), []source{public, local}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.Equal(t, "stun", d.Source)
+		require.True(t, d.CGNAT)
+		require.Equal(t, "100.64.1.3", d.LocalIP)
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		clock := newTestClock()
+		gateway := &cachedSource{
+			source: new(natpmpSource),
+			clock:  clock,
+			ip:     net.ParseIP("10.0.0.2"),
+			expire: clock.Now().Add(time.Hour),
+		}
+
+		d, err := updater.detect(context.Background(), []source{public, gateway}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.True(t, d.CGNAT)
+		require.Equal(t, "10.0.0.2", d.LocalIP)
+
+		gateway.ip = net.ParseIP("1.2.3.4")
+		d, err = updater.detect(context.Background(), []source{public, gateway}, "ipv4", addrPolicy{})
+		require.NoError(t, err)
+		require.False(t, d.CGNAT)
+		require.Empty(t, d.LocalIP)
+	})
+
+	t.Run("shared address", func(t *testing.T) {
+		local := testLocalSource("100.64.1.2")
+
+		policy := addrPolicy{allowBogon: true}
+		d, err := updater.detect(context.Background(), []source{local}, "ipv4", policy)
+		require.NoError(t, err)
+		require.Equal(t, "100.64.1.2", d.IP)
+		require.True(t, d.CGNAT)
+		require.Empty(t, d.LocalIP)
+	})
+}
+
+func TestUpdater_Update_CGNAT(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+	cfg.PublicIPv4.CGNAT = cgnatIPv6Only
+	cfg.PublicIPv6.Enabled = true
+	cfg.PublicIPv6.URL = "http://ipv6.example.com/"
+
+	publicIP := testRoundTripper(func(req *http.Request) (string, error) {
+		if req.URL.Host == "ipv4.example.com" {
+			return "1.2.3.4", nil
+		}
+		return "2400:cb00::1", nil
+	})
+	var (
+		pushed []string
+		mutex  sync.Mutex
+	)
+	provider := testRoundTripper(func(req *http.Request) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, req.URL.String())
+		return "good", nil
+	})
+	clock := newTestClock()
+	logger := new(testLogger)
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(publicIP), WithProviderTransport(provider),
+		WithClock(clock), WithLogger(logger),
+	)
+	require.NoError(t, err)
+	defer updater.Stop()
+	def := updater.uplinks[0]
+	def.ipv4Sources = append([]source{testLocalSource("100.64.1.2")}, def.ipv4Sources...)
+
+	report, err := updater.UpdateContext(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://provider.example.com/update?ip=2400:cb00::1"}, pushed)
+	require.True(t, report.IPv4.CGNAT)
+	require.True(t, report.IPv4.Skipped)
+	require.Contains(t, logger.Logs(), "[warning] ipv4 address is behind CGNAT, only update ipv6 records")
+
+	expected := "ipv4: 1.2.3.4 (http://ipv4.example.com/ in 0s), behind cgnat with local address 100.64.1.2, records skipped\n"
+	require.Contains(t, report.String(), expected)
+	require.True(t, updater.status.CGNAT)
+	require.Equal(t, "1.2.3.4", updater.status.IPv4)
+	expected = "ipv4: 1.2.3.4 (cgnat, skipped), ipv6: 2400:cb00::1, last update succeeded at " +
+		clock.Now().Format(time.RFC3339)
+	require.Equal(t, expected, updater.status.String())
+}
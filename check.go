@@ -0,0 +1,163 @@
+package ddns
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// checker is used to get the current value of the record before push,
+// if the record is already matched, the push will be skipped.
+type checker struct {
+	client    *dnsClient
+	resolver  Resolver
+	resolvers []string
+	timeout   time.Duration
+}
+
+func newChecker(cfg *Config, timeout time.Duration, resolver Resolver) *checker {
+	c := checker{
+		client:    new(dnsClient),
+		resolver:  resolver,
+		resolvers: cfg.Check.Resolvers,
+		timeout:   timeout,
+	}
+	return &c
+}
+
+// Resolve is used to query the record from the authoritative name servers
+// or the configured resolvers, it returns error if servers are inconsistent.
+func (c *checker) Resolve(ctx context.Context, domain string, typ uint16) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	recursion := len(c.resolvers) != 0
+	servers := c.resolvers
+	if !recursion {
+		var err error
+		servers, err = findNameservers(ctx, c.resolver, domain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var result []string
+	for i, server := range servers {
+		values, err := c.client.Lookup(ctx, server, domain, typ, recursion)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to query %s", server)
+		}
+		if i == 0 {
+			result = values
+			continue
+		}
+		if !equalIPs(result, values) {
+			return nil, errors.Errorf("name servers of %s are inconsistent", domain)
+		}
+	}
+	return result, nil
+}
+
+// upToDate is used to check the record of the provider is already
+// matched the detected address, if failed to check, it returns false.
+func (updater *Updater) upToDate(ctx context.Context, provider *provider, typ uint16, ip string) bool {
+	if updater.checker == nil {
+		return false
+	}
+	current, err := updater.getCurrent(ctx, provider, typ)
+	if err != nil {
+		updater.logger.Warningf("failed to check current %s record: %s", dnsTypeString(typ), err)
+		return false
+	}
+	if len(current) == 0 {
+		return false
+	}
+	expected := net.ParseIP(ip)
+	for _, values := range current {
+		if len(values) != 1 || !expected.Equal(net.ParseIP(values[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// getCurrent returns the current values of the record, the provider
+// defined request is preferred, otherwise it will query dns servers.
+func (updater *Updater) getCurrent(ctx context.Context, provider *provider, typ uint16) ([][]string, error) {
+	req, err := provider.NewCurrentRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		resp, err := updater.pushClient(provider, typ == dnsTypeA).Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}()
+		// the error page may contain the address of client
+		if resp.StatusCode/100 != 2 {
+			return nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		values := findIPs(string(data), typ == dnsTypeA)
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return [][]string{values}, nil
+	}
+	current := make([][]string, 0, len(provider.Domains))
+	for _, domain := range provider.Domains {
+		values, err := updater.checker.Resolve(ctx, domain, typ)
+		if err != nil {
+			return nil, err
+		}
+		current = append(current, values)
+	}
+	return current, nil
+}
+
+// findIPs is used to find the ip addresses in the text with family.
+func findIPs(text string, ipv4 bool) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'f', r >= 'A' && r <= 'F':
+			return false
+		case r == '.' || r == ':':
+			return false
+		default:
+			return true
+		}
+	})
+	var ips []string
+	for _, field := range fields {
+		ip := net.ParseIP(field)
+		if ip == nil {
+			continue
+		}
+		if (ip.To4() != nil) != ipv4 {
+			continue
+		}
+		ips = append(ips, ip.String())
+	}
+	return ips
+}
+
+func equalIPs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, value := range a {
+		if !containsIP(b, net.ParseIP(value)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker_Resolve(t *testing.T) {
+	server1 := newTestDNSServer(t)
+	server1.Set("test.example.com", dnsTypeA, "1.2.3.4")
+	server2 := newTestDNSServer(t)
+	server2.Set("test.example.com", dnsTypeA, "1.2.3.4")
+	ctx := context.Background()
+
+	cfg := Config{}
+	cfg.Check.Resolvers = []string{server1.Addr(), server2.Addr()}
+
+	t.Run("common", func(t *testing.T) {
+		c := newChecker(&cfg, defaultUpdateTimeout, net.DefaultResolver)
+		values, err := c.Resolve(ctx, "test.example.com", dnsTypeA)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.2.3.4"}, values)
+	})
+
+	t.Run("inconsistent", func(t *testing.T) {
+		server2.Set("test.example.com", dnsTypeA, "1.1.1.1")
+		defer server2.Set("test.example.com", dnsTypeA, "1.2.3.4")
+
+		c := newChecker(&cfg, defaultUpdateTimeout, net.DefaultResolver)
+		values, err := c.Resolve(ctx, "test.example.com", dnsTypeA)
+		require.EqualError(t, err, "name servers of test.example.com are inconsistent")
+		require.Nil(t, values)
+	})
+}
+
+func TestUpdater_CheckBeforePush(t *testing.T) {
+	server := newTestDNSServer(t)
+
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("1.2.3.4"))
+	}))
+	defer ipServer.Close()
+	var (
+		current string
+		status  int
+		pushed  int32
+	)
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/current" {
+			if status != 0 {
+				w.WriteHeader(status)
+			}
+			_, _ = w.Write([]byte(current))
+			return
+		}
+		atomic.AddInt32(&pushed, 1)
+		_, _ = w.Write([]byte("good"))
+	}))
+	defer providerServer.Close()
+
+	t.Run("dns", func(t *testing.T) {
+		cfg := testProviderConfig(t, providerServer.URL)
+		cfg.PublicIPv4.Enabled = true
+		cfg.PublicIPv4.URL = ipServer.URL
+		cfg.Check.Enabled = true
+		cfg.Check.Resolvers = []string{server.Addr()}
+
+		updater, err := NewUpdater(cfg)
+		require.NoError(t, err)
+		defer updater.Stop()
+		atomic.StoreInt32(&pushed, 0)
+
+		server.Set("test.example.com", dnsTypeA, "1.1.1.1")
+		updater.Update()
+		require.Equal(t, int32(1), atomic.LoadInt32(&pushed))
+
+		server.Set("test.example.com", dnsTypeA, "1.2.3.4")
+		updater.Update()
+		require.Equal(t, int32(1), atomic.LoadInt32(&pushed))
+		require.True(t, updater.status.Success)
+	})
+
+	t.Run("provider", func(t *testing.T) {
+		extra := "[current]\n  path = \"/current\"\n"
+		cfg := testProviderConfig(t, providerServer.URL, extra)
+		cfg.PublicIPv4.Enabled = true
+		cfg.PublicIPv4.URL = ipServer.URL
+		cfg.Check.Enabled = true
+
+		updater, err := NewUpdater(cfg)
+		require.NoError(t, err)
+		defer updater.Stop()
+		atomic.StoreInt32(&pushed, 0)
+
+		current = `{"ip":"1.1.1.1"}`
+		updater.Update()
+		require.Equal(t, int32(1), atomic.LoadInt32(&pushed))
+
+		current = `{"ip":"1.2.3.4","ttl":60}`
+		updater.Update()
+		require.Equal(t, int32(1), atomic.LoadInt32(&pushed))
+
+		// the error page that contains the address of client is not used
+		status = http.StatusUnauthorized
+		defer func() { status = 0 }()
+		current = "unauthorized request from 1.2.3.4"
+		updater.Update()
+		require.Equal(t, int32(2), atomic.LoadInt32(&pushed))
+	})
+}
+
+func TestFindIPs(t *testing.T) {
+	text := `{"a":"1.2.3.4","aaaa":"2400:cb00::1","ttl":600,"name":"abc.def"}`
+	require.Equal(t, []string{"1.2.3.4"}, findIPs(text, true))
+	require.Equal(t, []string{"2400:cb00::1"}, findIPs(text, false))
+	require.Empty(t, findIPs("nohost", true))
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/kardianos/service"
 	"github.com/pelletier/go-toml/v2"
@@ -16,58 +20,77 @@ import (
 var (
 	cfgPath   string
 	upOnce    bool
+	format    string
+	dryRun    bool
 	install   bool
 	uninstall bool
 	test      bool
 )
 
 func init() {
+	flag.Usage = usage
 	flag.StringVar(&cfgPath, "config", "config.toml", "configuration file path")
 	flag.BoolVar(&upOnce, "once", false, "update domain once")
+	flag.StringVar(&format, "format", "text", "report format with -once, text or json")
+	flag.BoolVar(&dryRun, "dry-run", false, "print requests instead of sending them")
 	flag.BoolVar(&install, "install", false, "install service")
 	flag.BoolVar(&uninstall, "uninstall", false, "uninstall service")
 	flag.BoolVar(&test, "test", false, "running with test mode")
 	flag.Parse()
 }
 
-type config struct {
-	ddns.Config
-
-	Service struct {
-		Name        string `toml:"name"`
-		DisplayName string `toml:"display_name"`
-		Description string `toml:"description"`
-	} `toml:"service"`
-}
-
 func main() {
-	// change current path for service and prevent get invalid path when test
-	if !test {
+	// the relative configuration file path is relative to the executable
+	// file for service, and prevent get invalid path when test
+	if !test && !filepath.IsAbs(cfgPath) {
 		path, err := os.Executable()
-		if err != nil {
-			log.Fatalln(err)
-		}
-		dir, _ := filepath.Split(path)
-		err = os.Chdir(dir)
-		if err != nil {
-			log.Fatalln(err)
-		}
+		checkError(err)
+		cfgPath = filepath.Join(filepath.Dir(path), cfgPath)
 	}
 
-	cfgData, err := os.ReadFile(cfgPath) // #nosec
-	checkError(err)
-	decoder := toml.NewDecoder(bytes.NewReader(cfgData))
-	decoder.DisallowUnknownFields()
+	if flag.Arg(0) == "validate" {
+		validate()
+		return
+	}
 
-	var cfg config
-	err = decoder.Decode(&cfg)
+	cfg, err := loadConfig()
 	checkError(err)
 
-	updater, err := ddns.NewUpdater(&cfg.Config)
+	// run sub command
+	switch flag.Arg(0) {
+	case "":
+	case "render":
+		render(cfg, flag.Args()[1:])
+		return
+	case "config":
+		if flag.Arg(1) != "dump" {
+			log.Fatalln("usage: config dump")
+		}
+		dumpConfig(cfg)
+		return
+	default:
+		log.Fatalf("unknown command: \"%s\"\n", flag.Arg(0))
+	}
+
+	if dryRun {
+		cfg.DryRun = true
+	}
+
+	var opts []ddns.Option
+	switch format {
+	case "text":
+	case "json":
+		// print log to stderr for parse the report from stdout
+		opts = append(opts, ddns.WithLogger(newStderrLogger()))
+	default:
+		log.Fatalf("unknown report format: \"%s\"\n", format)
+	}
+
+	updater, err := ddns.NewUpdater(cfg, opts...)
 	checkError(err)
 
 	if upOnce {
-		updater.Update()
+		updateOnce(updater)
 		return
 	}
 
@@ -105,6 +128,133 @@ func main() {
 	}
 }
 
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "usage: %s [options] [command]\n\n", os.Args[0])
+	_, _ = fmt.Fprintln(out, "commands:")
+	_, _ = fmt.Fprintln(out, "  render [-ipv4 ip] [-ipv6 ip] provider  print the requests of provider")
+	_, _ = fmt.Fprintln(out, "  validate                              check configuration and providers")
+	_, _ = fmt.Fprintln(out, "  config dump                           print the effective configuration")
+	_, _ = fmt.Fprintln(out, "\noptions:")
+	flag.PrintDefaults()
+}
+
+// loadConfig is used to load configuration file and apply environment
+// variables, the file can be omitted if the environment variables exist.
+func loadConfig() (*ddns.Config, error) {
+	cfg, err := ddns.LoadConfig(cfgPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) || !hasEnvConfig() {
+			return nil, err
+		}
+		cfg, err = ddns.ParseConfig(strings.NewReader(""))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = cfg.ApplyEnv()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func hasEnvConfig() bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, ddns.EnvPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dumpConfig is used to print the effective configuration with secrets redacted.
+func dumpConfig(cfg *ddns.Config) {
+	data, err := toml.Marshal(cfg.Redacted())
+	checkError(err)
+	fmt.Print(string(data))
+}
+
+// validate is used to check configuration and print all problems.
+func validate() {
+	err := ddns.ValidateConfig(cfgPath)
+	if err == nil {
+		log.Println("configuration is valid")
+		return
+	}
+	fmt.Println(err)
+	os.Exit(1)
+}
+
+// updateOnce is used to update once and print the report, it
+// will exit with non-zero code if failed to update any record.
+func updateOnce(updater *ddns.Updater) {
+	report, err := updater.UpdateContext(context.Background())
+	updater.Stop()
+	if format == "json" {
+		data, e := json.MarshalIndent(report, "", "  ")
+		checkError(e)
+		fmt.Println(string(data))
+	} else {
+		fmt.Print(report)
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// stderrLogger is used to print log to stderr.
+type stderrLogger struct {
+	logger *log.Logger
+}
+
+func newStderrLogger() *stderrLogger {
+	return &stderrLogger{logger: log.New(os.Stderr, "", log.LstdFlags)}
+}
+
+func (l *stderrLogger) Info(v ...interface{}) {
+	l.logger.Print("[info] " + fmt.Sprintln(v...))
+}
+
+func (l *stderrLogger) Infof(format string, v ...interface{}) {
+	l.logger.Printf("[info] "+format, v...)
+}
+
+func (l *stderrLogger) Warning(v ...interface{}) {
+	l.logger.Print("[warning] " + fmt.Sprintln(v...))
+}
+
+func (l *stderrLogger) Warningf(format string, v ...interface{}) {
+	l.logger.Printf("[warning] "+format, v...)
+}
+
+func (l *stderrLogger) Error(v ...interface{}) {
+	l.logger.Print("[error] " + fmt.Sprintln(v...))
+}
+
+func (l *stderrLogger) Errorf(format string, v ...interface{}) {
+	l.logger.Printf("[error] "+format, v...)
+}
+
+// render is used to print the requests of provider with sample addresses.
+func render(cfg *ddns.Config, args []string) {
+	var (
+		ipv4 string
+		ipv6 string
+	)
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	flags.StringVar(&ipv4, "ipv4", "203.0.113.1", "sample IPv4 address")
+	flags.StringVar(&ipv6, "ipv6", "2001:db8::1", "sample IPv6 address")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalln("render need a provider name")
+	}
+	output, err := ddns.RenderProvider(cfg, flags.Arg(0), ipv4, ipv6)
+	checkError(err)
+	fmt.Print(output)
+}
+
 func checkError(err error) {
 	if err != nil {
 		log.Fatalln(err)
@@ -117,7 +267,7 @@ type program struct {
 
 func (p *program) Start(service.Service) error {
 	p.updater.Run()
-	p.updater.Update()
+	p.updater.Trigger()
 	return nil
 }
 
@@ -1,50 +1,302 @@
 package ddns
 
 import (
+	"io"
+	"path/filepath"
 	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// default values about configuration.
+const (
+	defaultProviderDir        = "provider"
+	defaultServiceName        = "DDNS-Updater"
+	defaultServiceDisplayName = "DDNS-Updater"
+	defaultServiceDescription = "DDNS Updater Service"
 )
 
 // Config contains DDNS updater configurations.
 type Config struct {
-	Period  duration `toml:"period"`
-	Timeout duration `toml:"timeout"`
-	LogFile string   `toml:"log_file"`
-
-	PublicIPv4 struct {
-		Enabled   bool   `toml:"enabled"`
-		URL       string `toml:"url"`
-		ProxyURL  string `toml:"proxy"`
-		LocalAddr string `toml:"laddr"`
-	} `toml:"public_ipv4"`
-
-	PublicIPv6 struct {
-		Enabled   bool   `toml:"enabled"`
-		URL       string `toml:"url"`
-		ProxyURL  string `toml:"proxy"`
-		LocalAddr string `toml:"laddr"`
-	} `toml:"public_ipv6"`
+	Period      Duration `toml:"period"`
+	Timeout     Duration `toml:"timeout"`
+	LogFile     string   `toml:"log_file"`
+	LogJournald bool     `toml:"log_journald"`
+	LogSyslog   string   `toml:"log_syslog"`
+	LogTag      string   `toml:"log_tag"`
+	DryRun      bool     `toml:"dry_run"`
+
+	// Include is the file list or patterns that will be merged, it is
+	// only processed by LoadConfig, the relative paths in the included
+	// file are relative to the directory of the included file.
+	Include []string `toml:"include,omitempty"`
+
+	LogRotate struct {
+		MaxSize    int      `toml:"max_size"`
+		MaxAge     Duration `toml:"max_age"`
+		MaxBackups int      `toml:"max_backups"`
+		Compress   bool     `toml:"compress"`
+	} `toml:"log_rotate"`
+
+	PublicIPv4 PublicIP `toml:"public_ipv4"`
+	PublicIPv6 PublicIP `toml:"public_ipv6"`
+
+	// Uplink is the named uplink list about multi-WAN, the provider
+	// instance that references it will use the address of it.
+	Uplink []Uplink `toml:"uplink,omitempty"`
+
+	// Verify is used to check the records are propagated after push, the
+	// verification about the records of a provider is limited in the period.
+	Verify struct {
+		Enabled   bool     `toml:"enabled"`
+		Resolvers []string `toml:"resolvers"`
+		Timeout   Duration `toml:"timeout"`
+		Interval  Duration `toml:"interval"`
+	} `toml:"verify"`
+
+	Check struct {
+		Enabled   bool     `toml:"enabled"`
+		Resolvers []string `toml:"resolvers"`
+	} `toml:"check"`
 
 	Provider struct {
 		Dir      string   `toml:"dir"`
 		Item     []string `toml:"item"`
+		Glob     []string `toml:"glob"`
 		ProxyURL string   `toml:"proxy"`
+
+		// Interface is the network interface name that the requests to
+		// the DDNS providers are sent through, like "eth1".
+		Interface string `toml:"interface,omitempty"`
+
+		Instance []ProviderInstance `toml:"instance,omitempty"`
 	} `toml:"provider"`
+
+	Service struct {
+		Name        string `toml:"name"`
+		DisplayName string `toml:"display_name"`
+		Description string `toml:"description"`
+	} `toml:"service"`
+}
+
+// PublicIP contains the sources about the public IP address of a family.
+type PublicIP struct {
+	Enabled   bool   `toml:"enabled"`
+	URL       string `toml:"url"`
+	ProxyURL  string `toml:"proxy"`
+	LocalAddr string `toml:"laddr"`
+
+	// Interface is the network interface name that the url source is
+	// bound to, it is useful when the address is assigned by DHCP.
+	Interface string `toml:"interface,omitempty"`
+
+	// PrefixLen is the length about the delegated IPv6 prefix, the
+	// prefix of detected address is combined with the suffix of the
+	// provider instance, the default value is 64.
+	PrefixLen int `toml:"prefix_len,omitempty"`
+
+	// Policy is the selection policy about the local IPv6 address, if it
+	// is "stable", the http and stun sources without local address will be
+	// bound to the stable address that selected like the interface source,
+	// the address is selected on the interface of source if it is set, or
+	// the interface about the default IPv6 route.
+	Policy string `toml:"policy,omitempty"`
+
+	// AllowBogon is used to publish the private, shared and other
+	// special-use address returned by source, it is refused by default.
+	AllowBogon bool `toml:"allow_bogon,omitempty"`
+
+	// CGNAT is the action when the external address of gateway is
+	// different from the public address, or the address of interface or
+	// the public address is in the shared address space, it is only
+	// logged if empty, if it is "ipv6_only", the IPv4 records are not
+	// updated.
+	CGNAT string `toml:"cgnat,omitempty"`
+
+	// Source is the source list, the sources are tried in order until
+	// one of them succeeds, if it is empty, the url is used as source.
+	Source []SourceConfig `toml:"source,omitempty"`
+}
+
+// Uplink is a named WAN link that has own public IP address sources,
+// the sources can be bound to the local address of the link.
+type Uplink struct {
+	Name       string   `toml:"name"`
+	PublicIPv4 PublicIP `toml:"public_ipv4"`
+	PublicIPv6 PublicIP `toml:"public_ipv6"`
+}
+
+// SourceConfig is the configuration about a public IP address source,
+// the fields are used by the type of the source.
+type SourceConfig struct {
+	Type string `toml:"type"`
+
+	// about http source, the local address is also used by stun source
+	URL       string `toml:"url,omitempty"`
+	ProxyURL  string `toml:"proxy,omitempty"`
+	LocalAddr string `toml:"laddr,omitempty"`
+
+	// Format is the format about the response of http source and the
+	// output of exec source, it is "text" or "json", the key is the path
+	// of the address in json like "data.ip", the regexp is used to find
+	// the address in the text, the first submatch is used if it has.
+	Format string `toml:"format,omitempty"`
+	Key    string `toml:"key,omitempty"`
+	Regexp string `toml:"regexp,omitempty"`
+
+	// about exec source, the env is like "KEY=VALUE"
+	Command []string `toml:"command,omitempty"`
+	Dir     string   `toml:"dir,omitempty"`
+	Env     []string `toml:"env,omitempty"`
+
+	// about dns source, the record type is A, AAAA or TXT
+	Server string `toml:"server,omitempty"`
+	Name   string `toml:"name,omitempty"`
+	Record string `toml:"record,omitempty"`
+
+	// about stun source, the servers are tried in order
+	Servers []string `toml:"servers,omitempty"`
+
+	// Interface is the network interface name about interface source,
+	// the http, dns and stun sources are bound to it with SO_BINDTODEVICE
+	// on Linux, or its current address on other platforms.
+	Interface string `toml:"interface,omitempty"`
+
+	// Gateway is the address about the NAT-PMP/PCP gateway or the
+	// device description url about the UPnP gateway, if it is empty,
+	// the gateway will be discovered automatically.
+	Gateway string `toml:"gateway,omitempty"`
+
+	Timeout Duration `toml:"timeout,omitempty"`
+	Cache   Duration `toml:"cache,omitempty"`
+}
+
+// ProviderInstance is a provider that use the configuration file in the
+// provider directory as template and override the arguments of it.
+type ProviderInstance struct {
+	Name     string            `toml:"name"`
+	Template string            `toml:"template"`
+	Args     map[string]string `toml:"args"`
+
+	// IPv6Suffix or IPv6MAC is used to publish the address of other host
+	// behind the router, the interface identifier like "::1234" or the
+	// EUI-64 from MAC address is combined with the detected IPv6 prefix.
+	IPv6Suffix string `toml:"ipv6_suffix,omitempty"`
+	IPv6MAC    string `toml:"ipv6_mac,omitempty"`
+
+	// Uplink is the name about the uplink that provides the address,
+	// if it is empty, the public_ipv4 and public_ipv6 sections are used.
+	Uplink string `toml:"uplink,omitempty"`
+}
+
+// LoadConfig is used to load configuration from file and merge the files
+// referenced by the include directive, the relative provider directory
+// and log file path will be resolved relative to the directory of the
+// configuration file, the problems about the included files will be
+// returned as ValidationErrors.
+func LoadConfig(path string) (*Config, error) {
+	loader := newConfigLoader()
+	err := loader.Load(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open config file")
+	}
+	if len(loader.errs) != 0 {
+		return nil, loader.errs
+	}
+	cfg, err := loader.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfg.resolvePaths(filepath.Dir(path))
+	return cfg, nil
+}
+
+// ParseConfig is used to decode configuration with strict mode,
+// unknown fields will cause error, and the empty fields will be
+// filled with the default values. The include directive is not
+// supported, use LoadConfig instead.
+func ParseConfig(r io.Reader) (*Config, error) {
+	decoder := toml.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	cfg := new(Config)
+	err := decoder.Decode(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode config")
+	}
+	if len(cfg.Include) != 0 {
+		return nil, errors.New("include directive is only supported by LoadConfig")
+	}
+	cfg.SetDefaults()
+	return cfg, nil
+}
+
+// SetDefaults is used to fill the empty fields with the default values.
+func (cfg *Config) SetDefaults() {
+	if cfg.Period == 0 {
+		cfg.Period = Duration(defaultUpdatePeriod)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = Duration(defaultUpdateTimeout)
+	}
+	if cfg.LogTag == "" {
+		cfg.LogTag = defaultLogTag
+	}
+	if cfg.Verify.Timeout == 0 {
+		cfg.Verify.Timeout = Duration(defaultVerifyTimeout)
+	}
+	if cfg.Verify.Interval == 0 {
+		cfg.Verify.Interval = Duration(defaultVerifyInterval)
+	}
+	if cfg.Provider.Dir == "" {
+		cfg.Provider.Dir = defaultProviderDir
+	}
+	if cfg.Service.Name == "" {
+		cfg.Service.Name = defaultServiceName
+	}
+	if cfg.Service.DisplayName == "" {
+		cfg.Service.DisplayName = defaultServiceDisplayName
+	}
+	if cfg.Service.Description == "" {
+		cfg.Service.Description = defaultServiceDescription
+	}
+}
+
+// resolvePaths is used to make the relative paths relative to the dir.
+func (cfg *Config) resolvePaths(dir string) {
+	if cfg.Provider.Dir != "" && !filepath.IsAbs(cfg.Provider.Dir) {
+		cfg.Provider.Dir = filepath.Join(dir, cfg.Provider.Dir)
+	}
+	if cfg.LogFile != "" && !filepath.IsAbs(cfg.LogFile) {
+		cfg.LogFile = filepath.Join(dir, cfg.LogFile)
+	}
+	sections := [][]SourceConfig{cfg.PublicIPv4.Source, cfg.PublicIPv6.Source}
+	for _, uplink := range cfg.Uplink {
+		sections = append(sections, uplink.PublicIPv4.Source, uplink.PublicIPv6.Source)
+	}
+	for _, sources := range sections {
+		for i := 0; i < len(sources); i++ {
+			if sources[i].Dir != "" && !filepath.IsAbs(sources[i].Dir) {
+				sources[i].Dir = filepath.Join(dir, sources[i].Dir)
+			}
+		}
+	}
 }
 
-// duration is patch for toml v2.
-type duration time.Duration
+// Duration is patch for toml v2, it supports the format like "1m30s".
+type Duration time.Duration
 
 // MarshalText implement encoding.TextMarshaler.
-func (d duration) MarshalText() ([]byte, error) {
+func (d Duration) MarshalText() ([]byte, error) {
 	return []byte(time.Duration(d).String()), nil
 }
 
 // UnmarshalText implement encoding.TextUnmarshaler.
-func (d *duration) UnmarshalText(b []byte) error {
+func (d *Duration) UnmarshalText(b []byte) error {
 	x, err := time.ParseDuration(string(b))
 	if err != nil {
 		return err
 	}
-	*d = duration(x)
+	*d = Duration(x)
 	return nil
 }
@@ -1,6 +1,8 @@
 package ddns
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,11 +11,11 @@ import (
 )
 
 type testDuration struct {
-	Timeout duration `toml:"timeout"`
+	Timeout Duration `toml:"timeout"`
 }
 
 func TestDuration_MarshalText(t *testing.T) {
-	d := testDuration{Timeout: duration(time.Second)}
+	d := testDuration{Timeout: Duration(time.Second)}
 
 	data, err := toml.Marshal(d)
 	require.NoError(t, err)
@@ -41,3 +43,51 @@ func TestDuration_UnmarshalText(t *testing.T) {
 		require.EqualError(t, err, "toml: time: unknown unit \"as\" in duration \"1as\"")
 	})
 }
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("common", func(t *testing.T) {
+		cfg, err := LoadConfig("testdata/config.toml")
+		require.NoError(t, err)
+
+		require.Equal(t, time.Minute, time.Duration(cfg.Period))
+		require.Equal(t, 15*time.Second, time.Duration(cfg.Timeout))
+		require.Equal(t, filepath.Join("testdata", "ddns-updater.log"), cfg.LogFile)
+		require.Equal(t, "https://api.ipify.org/", cfg.PublicIPv4.URL)
+		require.Equal(t, "127.0.0.2:0", cfg.PublicIPv6.LocalAddr)
+		require.Equal(t, filepath.Join("testdata", "provider"), cfg.Provider.Dir)
+		require.DirExists(t, cfg.Provider.Dir)
+		require.Equal(t, []string{"provider1", "provider2"}, cfg.Provider.Item)
+		require.Equal(t, defaultServiceName, cfg.Service.Name)
+
+		providers, err := loadProviders(cfg)
+		require.NoError(t, err)
+		require.Len(t, providers, 2)
+		require.Equal(t, []string{"test.provider2.com"}, providers[1].Domains)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		cfg, err := LoadConfig("testdata/foo.toml")
+		require.Error(t, err)
+		require.Nil(t, cfg)
+	})
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := ParseConfig(strings.NewReader("[provider]\n  dir = \"/etc/ddns\"\n"))
+		require.NoError(t, err)
+
+		require.Equal(t, defaultUpdatePeriod, time.Duration(cfg.Period))
+		require.Equal(t, defaultUpdateTimeout, time.Duration(cfg.Timeout))
+		require.Equal(t, defaultLogTag, cfg.LogTag)
+		require.Equal(t, defaultVerifyTimeout, time.Duration(cfg.Verify.Timeout))
+		require.Equal(t, "/etc/ddns", cfg.Provider.Dir)
+		require.Equal(t, defaultServiceDescription, cfg.Service.Description)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		cfg, err := ParseConfig(strings.NewReader("foo = 1\n"))
+		require.EqualError(t, err, "failed to decode config: strict mode: fields in the document are missing in the target struct")
+		require.Nil(t, cfg)
+	})
+}
@@ -0,0 +1,88 @@
+package ddnstest
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DynDNS2Server is a fake provider server that compatible with the
+// dyndns2 protocol, the update path is "/nic/update" with the query
+// "hostname" and "myip", it uses basic authentication.
+type DynDNS2Server struct {
+	*Server
+
+	username string
+	password string
+	records  map[string]string
+	mutex    sync.Mutex
+}
+
+// NewDynDNS2Server is used to create a fake dyndns2 server, the hosts
+// must be added by AddHost before update, otherwise it returns "nohost".
+func NewDynDNS2Server(username, password string) *DynDNS2Server {
+	s := DynDNS2Server{
+		username: username,
+		password: password,
+		records:  make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nic/update", s.handleUpdate)
+	s.Server = newServer(mux)
+	return &s
+}
+
+// AddHost is used to add a host with the current address.
+func (s *DynDNS2Server) AddHost(hostname, ip string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records[hostname] = ip
+}
+
+// Record returns the current address about the host.
+func (s *DynDNS2Server) Record(hostname string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.records[hostname]
+}
+
+func (s *DynDNS2Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.username || password != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("badauth"))
+		return
+	}
+	query := r.URL.Query()
+	hostnames := query.Get("hostname")
+	if hostnames == "" {
+		_, _ = w.Write([]byte("notfqdn"))
+		return
+	}
+	ip := query.Get("myip")
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	if net.ParseIP(ip) == nil {
+		_, _ = w.Write([]byte("dnserr"))
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var results []string
+	for _, hostname := range strings.Split(hostnames, ",") {
+		current, ok := s.records[hostname]
+		switch {
+		case !ok:
+			results = append(results, "nohost")
+		case current == ip:
+			results = append(results, "nochg "+ip)
+		default:
+			s.records[hostname] = ip
+			results = append(results, "good "+ip)
+		}
+	}
+	_, _ = w.Write([]byte(strings.Join(results, "\n")))
+}
@@ -0,0 +1,86 @@
+package ddnstest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/For-ACGN/DDNS-Updater"
+)
+
+func TestDynDNS2Server(t *testing.T) {
+	server := NewDynDNS2Server("user", "pass")
+	defer server.Close()
+	server.AddHost("a.example.com", "1.1.1.1")
+	server.AddHost("b.example.com", "1.2.3.4")
+
+	newRequest := func(query string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/nic/update?"+query, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("user", "pass")
+		return req
+	}
+	do := func(req *http.Request) string {
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		_, body := readResponse(t, resp)
+		return body
+	}
+
+	t.Run("update", func(t *testing.T) {
+		req := newRequest("hostname=a.example.com,b.example.com,c.example.com&myip=1.2.3.4")
+		require.Equal(t, "good 1.2.3.4\nnochg 1.2.3.4\nnohost", do(req))
+		require.Equal(t, "1.2.3.4", server.Record("a.example.com"))
+	})
+
+	t.Run("remote address", func(t *testing.T) {
+		req := newRequest("hostname=a.example.com")
+		require.Equal(t, "good 127.0.0.1", do(req))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.Equal(t, "notfqdn", do(newRequest("myip=1.2.3.4")))
+		require.Equal(t, "dnserr", do(newRequest("hostname=a.example.com&myip=foo")))
+
+		req := newRequest("hostname=a.example.com")
+		req.SetBasicAuth("user", "foo")
+		require.Equal(t, "badauth", do(req))
+	})
+}
+
+func TestDynDNS2Server_Provider(t *testing.T) {
+	ipServer := NewIPServer("1.2.3.4")
+	defer ipServer.Close()
+	server := NewDynDNS2Server("user", "pass")
+	defer server.Close()
+	server.AddHost("home.ddns.net", "1.1.1.1")
+
+	cfg := new(ddns.Config)
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = ipServer.URL
+	cfg.Provider.Dir = "../provider"
+	cfg.Provider.Instance = []ddns.ProviderInstance{{
+		Name:     "home",
+		Template: "noip",
+		Args: map[string]string{
+			"hostname": "home.ddns.net",
+			"username": "user",
+			"password": "pass",
+		},
+	}}
+
+	// the host of the provider is fixed, so redirect it to the server
+	updater, err := ddns.NewUpdater(cfg, ddns.WithProviderTransport(server.Transport()))
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	updater.Update()
+	require.Equal(t, "1.2.3.4", server.Record("home.ddns.net"))
+
+	r := server.LastRequest()
+	require.Equal(t, "dynupdate.no-ip.com", r.Host)
+	require.Equal(t, "/nic/update", r.URL.Path)
+	require.Equal(t, "home.ddns.net", r.URL.Query().Get("hostname"))
+}
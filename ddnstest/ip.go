@@ -0,0 +1,51 @@
+package ddnstest
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// IPServer is a fake public IP address service, it responds the
+// configured address or the remote address about the client.
+type IPServer struct {
+	*Server
+
+	ip    string
+	mutex sync.Mutex
+}
+
+// NewIPServer is used to create a fake public IP address service,
+// if the ip is empty, it will respond the address about the client.
+// The server listens on IPv6 loopback if the ip is an IPv6 address,
+// because the IPv6 source only connects the service over IPv6.
+func NewIPServer(ip string) *IPServer {
+	s := IPServer{ip: ip}
+	handler := http.HandlerFunc(s.serveHTTP)
+	addr := net.ParseIP(ip)
+	if addr != nil && addr.To4() == nil {
+		s.Server = newIPv6Server(handler)
+	} else {
+		s.Server = newServer(handler)
+	}
+	return &s
+}
+
+// SetIP is used to change the responded address, it is used to
+// simulate the public IP address is changed.
+func (s *IPServer) SetIP(ip string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ip = ip
+}
+
+func (s *IPServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	ip := s.ip
+	s.mutex.Unlock()
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(ip))
+}
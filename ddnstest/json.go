@@ -0,0 +1,124 @@
+package ddnstest
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// JSONRecord is the record about the JSON API.
+type JSONRecord struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+type jsonResponse struct {
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+	Result  *JSONRecord `json:"result,omitempty"`
+}
+
+// JSONServer is a fake provider server with a generic JSON API, the token
+// is sent by the bearer authorization header or the query "token", the
+// API about records are:
+//
+//	GET /records/{name}  get the current record
+//	PUT /records/{name}  update the record with {"content":"1.2.3.4"}
+//
+// POST is the same as PUT, the response is like {"success":true,"result":{}}.
+type JSONServer struct {
+	*Server
+
+	token   string
+	records map[string]*JSONRecord
+	mutex   sync.Mutex
+}
+
+// NewJSONServer is used to create a fake JSON API server, if the token
+// is empty, the authentication is disabled. The records must be added
+// by AddRecord before update, otherwise it returns 404.
+func NewJSONServer(token string) *JSONServer {
+	s := JSONServer{
+		token:   token,
+		records: make(map[string]*JSONRecord),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/records/", s.handleRecord)
+	s.Server = newServer(mux)
+	return &s
+}
+
+// AddRecord is used to add a record with the current address.
+func (s *JSONServer) AddRecord(name, content string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records[name] = &JSONRecord{Name: name, Content: content, TTL: 60}
+}
+
+// Record returns the current address about the record.
+func (s *JSONServer) Record(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record, ok := s.records[name]
+	if !ok {
+		return ""
+	}
+	return record.Content
+}
+
+func (s *JSONServer) handleRecord(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, &jsonResponse{Error: "unauthorized"})
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/records/")
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record, ok := s.records[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, &jsonResponse{Error: "record not found"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req JSONRecord
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &jsonResponse{Error: "invalid request body"})
+			return
+		}
+		if net.ParseIP(req.Content) == nil {
+			writeJSON(w, http.StatusBadRequest, &jsonResponse{Error: "invalid content"})
+			return
+		}
+		record.Content = req.Content
+		if req.TTL != 0 {
+			record.TTL = req.TTL
+		}
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, &jsonResponse{Error: "method not allowed"})
+		return
+	}
+	r2 := *record
+	writeJSON(w, http.StatusOK, &jsonResponse{Success: true, Result: &r2})
+}
+
+func (s *JSONServer) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	if r.Header.Get("Authorization") == "Bearer "+s.token {
+		return true
+	}
+	return r.URL.Query().Get("token") == s.token
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp *jsonResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
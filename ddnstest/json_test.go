@@ -0,0 +1,112 @@
+package ddnstest
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/For-ACGN/DDNS-Updater"
+)
+
+func TestJSONServer(t *testing.T) {
+	server := NewJSONServer("token")
+	defer server.Close()
+	server.AddRecord("home", "1.1.1.1")
+
+	do := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		return readResponse(t, resp)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		status, body := do(http.MethodGet, "/records/home", "")
+		require.Equal(t, http.StatusOK, status)
+		expected := `{"success":true,"result":{"name":"home","content":"1.1.1.1","ttl":60}}`
+		require.JSONEq(t, expected, body)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		status, body := do(http.MethodPut, "/records/home", `{"content":"1.2.3.4"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Contains(t, body, `"success":true`)
+		require.Equal(t, "1.2.3.4", server.Record("home"))
+	})
+
+	t.Run("token in query", func(t *testing.T) {
+		status, _ := testGet(t, server.Client(), server.URL+"/records/home?token=token")
+		require.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		status, _ := do(http.MethodGet, "/records/foo", "")
+		require.Equal(t, http.StatusNotFound, status)
+
+		status, _ = do(http.MethodPut, "/records/home", `{"content":"foo"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = do(http.MethodDelete, "/records/home", "")
+		require.Equal(t, http.StatusMethodNotAllowed, status)
+
+		status, body := testGet(t, server.Client(), server.URL+"/records/home")
+		require.Equal(t, http.StatusUnauthorized, status)
+		require.JSONEq(t, `{"success":false,"error":"unauthorized"}`, body)
+	})
+}
+
+func TestJSONServer_Provider(t *testing.T) {
+	ipServer := NewIPServer("2400:cb00::1")
+	defer ipServer.Close()
+	server := NewJSONServer("token")
+	defer server.Close()
+	server.AddRecord("home", "2400:cb00::2")
+
+	const provider = `
+[meta]
+  host_url = "{{.host}}"
+  method   = "PUT"
+  response = "\"success\":true"
+
+[ipv6]
+  path = "/records/{{.name}}?token={{.token}}"
+  body = "{\"content\":\"{{.ipv6}}\"}"
+
+[args]
+  name  = "home"
+  token = "token"
+`
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "json.toml"), []byte(provider), 0600)
+	require.NoError(t, err)
+
+	cfg := new(ddns.Config)
+	cfg.PublicIPv6.Enabled = true
+	cfg.PublicIPv6.URL = ipServer.URL
+	cfg.Provider.Dir = dir
+	cfg.Provider.Instance = []ddns.ProviderInstance{{
+		Name:     "home",
+		Template: "json",
+		Args:     map[string]string{"host": server.URL},
+	}}
+
+	updater, err := ddns.NewUpdater(cfg)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	server.FailNext(1, http.StatusInternalServerError, "internal error")
+	updater.Update()
+	require.Equal(t, "2400:cb00::2", server.Record("home"))
+
+	updater.Update()
+	require.Equal(t, "2400:cb00::1", server.Record("home"))
+	require.Len(t, server.Requests(), 2)
+	require.Equal(t, `{"content":"2400:cb00::1"}`, string(server.LastRequest().Body))
+}
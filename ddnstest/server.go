@@ -0,0 +1,167 @@
+// Package ddnstest provides fake public IP address services and DDNS
+// provider servers for test provider configuration files and custom
+// providers without network.
+package ddnstest
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Request is a request received by the fake server.
+type Request struct {
+	Method     string
+	URL        *url.URL
+	Host       string
+	Header     http.Header
+	Body       []byte
+	RemoteAddr string
+}
+
+// Server is a fake server that records the received requests, it
+// can inject latency and failures for test the error handling.
+type Server struct {
+	*httptest.Server
+
+	handler http.Handler
+
+	latency    time.Duration
+	failures   int
+	failStatus int
+	failBody   string
+	requests   []*Request
+	mutex      sync.Mutex
+}
+
+func newServer(handler http.Handler) *Server {
+	s := Server{handler: handler}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return &s
+}
+
+// newIPv6Server is used to create server that listens on IPv6 loopback,
+// if IPv6 is not available, it listens on IPv4 loopback like newServer.
+func newIPv6Server(handler http.Handler) *Server {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		return newServer(handler)
+	}
+	s := Server{handler: handler}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	_ = s.Server.Listener.Close()
+	s.Server.Listener = listener
+	s.Server.Start()
+	return &s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	req := Request{
+		Method:     r.Method,
+		URL:        r.URL,
+		Host:       r.Host,
+		Header:     r.Header.Clone(),
+		Body:       body,
+		RemoteAddr: r.RemoteAddr,
+	}
+	s.mutex.Lock()
+	s.requests = append(s.requests, &req)
+	latency := s.latency
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	status := s.failStatus
+	failBody := s.failBody
+	s.mutex.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fail {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(failBody))
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+// Requests returns the received requests.
+func (s *Server) Requests() []*Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Request{}, s.requests...)
+}
+
+// LastRequest returns the last received request, if no request
+// is received, it will return nil.
+func (s *Server) LastRequest() *Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// ResetRequests is used to clean the recorded requests.
+func (s *Server) ResetRequests() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = nil
+}
+
+// SetLatency is used to delay each response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = d
+}
+
+// FailNext is used to make the next n requests failed with the
+// status code and body, the requests are still recorded.
+func (s *Server) FailNext(n, status int, body string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = n
+	s.failStatus = status
+	s.failBody = body
+}
+
+// Transport returns a transport that sends all requests to the server
+// whatever the host of the request url, it is used to test provider
+// configuration files that the host is fixed. The original host is
+// kept in the Host header.
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	return &redirectTransport{
+		target: target,
+		base:   s.Client().Transport,
+	}
+}
+
+type redirectTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	r.URL.Scheme = rt.target.Scheme
+	r.URL.Host = rt.target.Host
+	r.URL.User = nil
+	return rt.base.RoundTrip(r)
+}
@@ -0,0 +1,262 @@
+package ddns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DNS record types.
+const (
+	dnsTypeA     uint16 = 1
+	dnsTypeNS    uint16 = 2
+	dnsTypeCNAME uint16 = 5
+	dnsTypeTXT   uint16 = 16
+	dnsTypeAAAA  uint16 = 28
+)
+
+const dnsMaxUDPSize = 4096
+
+var dnsTypeNames = map[uint16]string{
+	dnsTypeA:     "A",
+	dnsTypeNS:    "NS",
+	dnsTypeCNAME: "CNAME",
+	dnsTypeTXT:   "TXT",
+	dnsTypeAAAA:  "AAAA",
+}
+
+func dnsTypeString(typ uint16) string {
+	name, ok := dnsTypeNames[typ]
+	if ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(typ))
+}
+
+func parseDNSType(name string) (uint16, error) {
+	for typ, n := range dnsTypeNames {
+		if strings.EqualFold(n, name) {
+			return typ, nil
+		}
+	}
+	return 0, errors.Errorf("unsupported dns record type: \"%s\"", name)
+}
+
+// newDNSQuery is used to create the query message with a random id.
+func newDNSQuery(name string, typ uint16, recursion bool) ([]byte, uint16, error) {
+	n, err := dnsmessage.NewName(dnsFQDN(name))
+	if err != nil {
+		return nil, 0, errors.Errorf("invalid domain name: \"%s\"", name)
+	}
+	id := make([]byte, 2)
+	_, _ = rand.Read(id)
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               binary.BigEndian.Uint16(id),
+			RecursionDesired: recursion,
+		},
+		Questions: []dnsmessage.Question{{
+			Name:  n,
+			Type:  dnsmessage.Type(typ),
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "invalid domain name: \"%s\"", name)
+	}
+	return data, msg.Header.ID, nil
+}
+
+// dnsFQDN will add the trailing dot if the name has not.
+func dnsFQDN(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// dnsRecordValue returns the text format about A, AAAA, NS, CNAME and
+// TXT record, the multi strings of TXT record are joined.
+func dnsRecordValue(body dnsmessage.ResourceBody) string {
+	switch rr := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(rr.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(rr.AAAA[:]).String()
+	case *dnsmessage.NSResource:
+		return rr.NS.String()
+	case *dnsmessage.CNAMEResource:
+		return rr.CNAME.String()
+	case *dnsmessage.TXTResource:
+		return strings.Join(rr.TXT, "")
+	default:
+		return ""
+	}
+}
+
+// dnsClient is used to send query to the specified dns server,
+// it will retry with tcp if the udp response is truncated.
+type dnsClient struct {
+	// family is "", "4" or "6", it will be appended to the network.
+	family string
+	dialer net.Dialer
+
+	// iface is the network interface that the query is sent through.
+	iface string
+}
+
+// Exchange is used to send query and receive the response.
+func (c *dnsClient) Exchange(ctx context.Context, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	server = dnsServerAddress(server)
+	resp, err := c.exchange(ctx, "udp"+c.family, server, id, query)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Header.Truncated {
+		return resp, nil
+	}
+	return c.exchange(ctx, "tcp"+c.family, server, id, query)
+}
+
+func (c *dnsClient) exchange(ctx context.Context, network, server string, id uint16, data []byte) (*dnsmessage.Message, error) {
+	dialer := c.dialer
+	if c.iface != "" {
+		err := bindInterface(&dialer, c.iface, network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultUpdateTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+	stream := !strings.HasPrefix(network, "udp")
+	if stream {
+		buf := make([]byte, 2, 2+len(data))
+		binary.BigEndian.PutUint16(buf, uint16(len(data)))
+		data = append(buf, data...)
+	}
+	_, err = conn.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var buf []byte
+		if stream {
+			size := make([]byte, 2)
+			_, err = io.ReadFull(conn, size)
+			if err != nil {
+				return nil, err
+			}
+			buf = make([]byte, binary.BigEndian.Uint16(size))
+			_, err = io.ReadFull(conn, buf)
+		} else {
+			buf = make([]byte, dnsMaxUDPSize)
+			var n int
+			n, err = conn.Read(buf)
+			buf = buf[:n]
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp := new(dnsmessage.Message)
+		err = resp.Unpack(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid dns response")
+		}
+		// skip the unexpected response about udp
+		if !resp.Header.Response || resp.Header.ID != id {
+			if stream {
+				return nil, errors.New("unexpected dns response")
+			}
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// Lookup is used to query records and return the values in answer section.
+func (c *dnsClient) Lookup(ctx context.Context, server, name string, typ uint16, recursion bool) ([]string, error) {
+	query, id, err := newDNSQuery(name, typ, recursion)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Exchange(ctx, server, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, errors.Errorf("dns server %s returned rcode %d", server, resp.Header.RCode)
+	}
+	var values []string
+	for _, rr := range resp.Answers {
+		if rr.Header.Type == dnsmessage.Type(typ) {
+			values = append(values, dnsRecordValue(rr.Body))
+		}
+	}
+	return values, nil
+}
+
+// dnsServerAddress will add the default port if the address has no port.
+func dnsServerAddress(server string) string {
+	_, _, err := net.SplitHostPort(server)
+	if err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// findNameservers is used to find the authoritative name servers about
+// the domain, it will walk up the labels until find the zone.
+func findNameservers(ctx context.Context, resolver Resolver, domain string) ([]string, error) {
+	zone := strings.TrimSuffix(domain, ".")
+	var lastErr error
+	for zone != "" {
+		records, err := resolver.LookupNS(ctx, zone)
+		if err == nil && len(records) != 0 {
+			var servers []string
+			for _, ns := range records {
+				addrs, err := resolver.LookupHost(ctx, ns.Host)
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				for _, addr := range addrs {
+					servers = append(servers, net.JoinHostPort(addr, "53"))
+				}
+			}
+			if len(servers) == 0 && lastErr == nil {
+				return nil, errors.Errorf("failed to resolve name servers of %s", zone)
+			}
+			if len(servers) == 0 {
+				return nil, errors.WithMessagef(lastErr, "failed to resolve name servers of %s", zone)
+			}
+			return servers, nil
+		}
+		lastErr = err
+		i := strings.Index(zone, ".")
+		if i == -1 {
+			break
+		}
+		zone = zone[i+1:]
+	}
+	if lastErr == nil {
+		return nil, errors.Errorf("failed to find name servers of %s", domain)
+	}
+	return nil, errors.WithMessagef(lastErr, "failed to find name servers of %s", domain)
+}
@@ -0,0 +1,315 @@
+package ddns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSServer is an in-process dns server over udp and tcp.
+type testDNSServer struct {
+	udp net.PacketConn
+	tcp net.Listener
+
+	records  map[string][]string
+	truncate bool
+	queries  int
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func newTestDNSServer(t *testing.T) *testDNSServer {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	require.NoError(t, err)
+	server := testDNSServer{
+		udp:     udp,
+		tcp:     tcp,
+		records: make(map[string][]string),
+	}
+	server.wg.Add(2)
+	go server.serveUDP()
+	go server.serveTCP()
+	t.Cleanup(server.Close)
+	return &server
+}
+
+func (s *testDNSServer) Addr() string {
+	return s.udp.LocalAddr().String()
+}
+
+// Set is used to set the record values, empty values will remove it.
+func (s *testDNSServer) Set(name string, typ uint16, values ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := strings.ToLower(strings.TrimSuffix(name, ".")) + "/" + dnsTypeString(typ)
+	if len(values) == 0 {
+		delete(s.records, key)
+		return
+	}
+	s.records[key] = values
+}
+
+func (s *testDNSServer) SetTruncate(truncate bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.truncate = truncate
+}
+
+func (s *testDNSServer) Queries() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.queries
+}
+
+func (s *testDNSServer) handle(data []byte, udp bool) []byte {
+	query := new(dnsmessage.Message)
+	err := query.Unpack(data)
+	if err != nil || len(query.Questions) == 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queries++
+	q := query.Questions[0]
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 query.Header.ID,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   query.Header.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: []dnsmessage.Question{q},
+	}
+	if udp && s.truncate {
+		resp.Header.Truncated = true
+	} else {
+		key := strings.ToLower(strings.TrimSuffix(q.Name.String(), ".")) + "/" + dnsTypeString(uint16(q.Type))
+		for _, value := range s.records[key] {
+			body := testDNSRecord(q.Type, value)
+			if body == nil {
+				return nil
+			}
+			rr := dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{
+					Name:  q.Name,
+					Class: dnsmessage.ClassINET,
+					TTL:   60,
+				},
+				Body: body,
+			}
+			resp.Answers = append(resp.Answers, rr)
+		}
+	}
+	b, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// testDNSRecord returns the record body from the text format value.
+func testDNSRecord(typ dnsmessage.Type, value string) dnsmessage.ResourceBody {
+	switch typ {
+	case dnsmessage.TypeA:
+		rr := new(dnsmessage.AResource)
+		copy(rr.A[:], net.ParseIP(value).To4())
+		return rr
+	case dnsmessage.TypeAAAA:
+		rr := new(dnsmessage.AAAAResource)
+		copy(rr.AAAA[:], net.ParseIP(value).To16())
+		return rr
+	case dnsmessage.TypeNS:
+		return &dnsmessage.NSResource{NS: dnsmessage.MustNewName(value)}
+	case dnsmessage.TypeCNAME:
+		return &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(value)}
+	case dnsmessage.TypeTXT:
+		var txt []string
+		for len(value) > 255 {
+			txt = append(txt, value[:255])
+			value = value[255:]
+		}
+		return &dnsmessage.TXTResource{TXT: append(txt, value)}
+	default:
+		return nil
+	}
+}
+
+func (s *testDNSServer) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, dnsMaxUDPSize)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		resp := s.handle(buf[:n], true)
+		if resp != nil {
+			_, _ = s.udp.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *testDNSServer) serveTCP() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			for {
+				size := make([]byte, 2)
+				_, err := io.ReadFull(conn, size)
+				if err != nil {
+					return
+				}
+				data := make([]byte, binary.BigEndian.Uint16(size))
+				_, err = io.ReadFull(conn, data)
+				if err != nil {
+					return
+				}
+				resp := s.handle(data, false)
+				if resp == nil {
+					return
+				}
+				buf := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+				_, err = conn.Write(append(buf, resp...))
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (s *testDNSServer) Close() {
+	_ = s.udp.Close()
+	_ = s.tcp.Close()
+	s.wg.Wait()
+}
+
+// Resolver is used to create a go resolver that use this server.
+func (s *testDNSServer) Resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, s.Addr())
+		},
+	}
+}
+
+func TestDNSClient(t *testing.T) {
+	server := newTestDNSServer(t)
+	server.Set("test.example.com", dnsTypeA, "1.2.3.4", "1.2.3.5")
+	client := new(dnsClient)
+	ctx := context.Background()
+
+	t.Run("udp", func(t *testing.T) {
+		values, err := client.Lookup(ctx, server.Addr(), "test.example.com.", dnsTypeA, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.2.3.4", "1.2.3.5"}, values)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		server.SetTruncate(true)
+		defer server.SetTruncate(false)
+
+		values, err := client.Lookup(ctx, server.Addr(), "test.example.com.", dnsTypeA, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1.2.3.4", "1.2.3.5"}, values)
+	})
+
+	t.Run("record types", func(t *testing.T) {
+		server.Set("example.com", dnsTypeAAAA, "2400:cb00::1")
+		server.Set("example.com", dnsTypeTXT, strings.Repeat("a", 300))
+		server.Set("example.com", dnsTypeNS, "ns1.example.com.")
+		server.Set("www.example.com", dnsTypeCNAME, "example.net.")
+
+		values, err := client.Lookup(ctx, server.Addr(), "example.com", dnsTypeAAAA, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2400:cb00::1"}, values)
+		values, err = client.Lookup(ctx, server.Addr(), "example.com", dnsTypeTXT, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{strings.Repeat("a", 300)}, values)
+		values, err = client.Lookup(ctx, server.Addr(), "example.com", dnsTypeNS, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"ns1.example.com."}, values)
+		values, err = client.Lookup(ctx, server.Addr(), "www.example.com", dnsTypeCNAME, false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"example.net."}, values)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		values, err := client.Lookup(ctx, server.Addr(), strings.Repeat("a", 64)+".com", dnsTypeA, false)
+		require.ErrorContains(t, err, "invalid domain name")
+		require.Nil(t, values)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		values, err := client.Lookup(ctx, server.Addr(), "foo.example.com.", dnsTypeAAAA, true)
+		require.NoError(t, err)
+		require.Empty(t, values)
+	})
+}
+
+func TestFindNameservers(t *testing.T) {
+	server := newTestDNSServer(t)
+	server.Set("example.com", dnsTypeNS, "ns1.example.com.", "ns2.example.com.")
+	server.Set("ns1.example.com", dnsTypeA, "127.0.0.1")
+	server.Set("ns2.example.com", dnsTypeA, "127.0.0.2")
+
+	resolver := server.Resolver()
+
+	servers, err := findNameservers(context.Background(), resolver, "test.example.com")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"127.0.0.1:53", "127.0.0.2:53"}, servers)
+
+	// the resolver returns no records without error
+	servers, err = findNameservers(context.Background(), new(testEmptyResolver), "test.example.com")
+	require.EqualError(t, err, "failed to find name servers of test.example.com")
+	require.Nil(t, servers)
+
+	servers, err = findNameservers(context.Background(), &testEmptyResolver{ns: true}, "test.example.com")
+	require.EqualError(t, err, "failed to resolve name servers of test.example.com")
+	require.Nil(t, servers)
+}
+
+// testEmptyResolver is a resolver that returns empty result without error,
+// if ns is true, it returns a name server without address.
+type testEmptyResolver struct {
+	ns bool
+}
+
+func (r *testEmptyResolver) LookupNS(context.Context, string) ([]*net.NS, error) {
+	if r.ns {
+		return []*net.NS{{Host: "ns1.example.com."}}, nil
+	}
+	return nil, nil
+}
+
+func (r *testEmptyResolver) LookupHost(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func TestDNSServerAddress(t *testing.T) {
+	require.Equal(t, "1.1.1.1:53", dnsServerAddress("1.1.1.1"))
+	require.Equal(t, "1.1.1.1:5353", dnsServerAddress("1.1.1.1:5353"))
+	require.Equal(t, "[::1]:53", dnsServerAddress("::1"))
+	require.Equal(t, "[::1]:53", dnsServerAddress("[::1]"))
+}
@@ -0,0 +1,277 @@
+package ddns
+
+import (
+	"encoding"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix is the prefix of environment variables that override
+// configuration, like DDNS_PERIOD and DDNS_PUBLIC_IPV4_URL.
+const EnvPrefix = "DDNS_"
+
+// provider instance environment variables like:
+// DDNS_PROVIDER_INSTANCE_<NAME>_TEMPLATE = "noip"
+// DDNS_PROVIDER_INSTANCE_<NAME>_ARGS_<KEY> = "value"
+// DDNS_PROVIDER_INSTANCE_<NAME>_IPV6_SUFFIX = "::1234"
+// DDNS_PROVIDER_INSTANCE_<NAME>_IPV6_MAC = "00:11:22:33:44:55"
+// DDNS_PROVIDER_INSTANCE_<NAME>_UPLINK = "wan1"
+const (
+	envInstancePrefix     = EnvPrefix + "PROVIDER_INSTANCE_"
+	envInstanceTemplate   = "_TEMPLATE"
+	envInstanceArgs       = "_ARGS_"
+	envInstanceIPv6Suffix = "_IPV6_SUFFIX"
+	envInstanceIPv6MAC    = "_IPV6_MAC"
+	envInstanceUplink     = "_UPLINK"
+)
+
+// ApplyEnv is used to override configuration with environment variables,
+// the name of variable is the upper case of the toml key path that joined
+// by underscore with the prefix "DDNS_", the list is separated by comma.
+func (cfg *Config) ApplyEnv() error {
+	return cfg.applyEnv(os.Environ())
+}
+
+func (cfg *Config) applyEnv(environ []string) error {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i == -1 {
+			continue
+		}
+		if strings.HasPrefix(kv[:i], EnvPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	if _, ok := env[EnvPrefix+"INCLUDE"]; ok {
+		return errors.New("include directive can not be set by environment variable")
+	}
+	err := applyEnvStruct(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"), env)
+	if err != nil {
+		return err
+	}
+	return cfg.applyInstanceEnv(env)
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func applyEnvStruct(v reflect.Value, prefix string, env map[string]string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && !fv.Addr().Type().Implements(textUnmarshaler) {
+			err := applyEnvStruct(fv, name, env)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := env[name]
+		if !ok {
+			continue
+		}
+		err := setEnvValue(fv, value)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid environment variable %s", name)
+		}
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	if v.Addr().Type().Implements(textUnmarshaler) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported type %s", v.Type())
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// applyInstanceEnv is used to override or append provider instances,
+// the instance name and the argument name are case-insensitive.
+func (cfg *Config) applyInstanceEnv(env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if strings.HasPrefix(name, envInstancePrefix) {
+			names = append(names, name)
+		}
+	}
+	// make the order of appended instances stable
+	sort.Strings(names)
+	for _, name := range names {
+		value := env[name]
+		key := name[len(envInstancePrefix):]
+		var arg, field string
+		switch {
+		case strings.HasSuffix(key, envInstanceTemplate):
+			field = envInstanceTemplate
+		case strings.HasSuffix(key, envInstanceIPv6Suffix):
+			field = envInstanceIPv6Suffix
+		case strings.HasSuffix(key, envInstanceIPv6MAC):
+			field = envInstanceIPv6MAC
+		case strings.HasSuffix(key, envInstanceUplink):
+			field = envInstanceUplink
+		case strings.Contains(key, envInstanceArgs):
+			i := strings.Index(key, envInstanceArgs)
+			arg = key[i+len(envInstanceArgs):]
+			key = key[:i]
+			if arg == "" {
+				return errors.Errorf("empty argument name in environment variable %s", name)
+			}
+		default:
+			return errors.Errorf("invalid environment variable %s", name)
+		}
+		key = strings.TrimSuffix(key, field)
+		if key == "" {
+			return errors.Errorf("empty instance name in environment variable %s", name)
+		}
+		instance := cfg.providerInstance(key)
+		switch field {
+		case envInstanceTemplate:
+			instance.Template = value
+			continue
+		case envInstanceIPv6Suffix:
+			instance.IPv6Suffix = value
+			continue
+		case envInstanceIPv6MAC:
+			instance.IPv6MAC = value
+			continue
+		case envInstanceUplink:
+			instance.Uplink = value
+			continue
+		}
+		if instance.Args == nil {
+			instance.Args = make(map[string]string)
+		}
+		// the new argument is matched with the template when load provider
+		instance.Args[argName(instance.Args, strings.ToLower(arg))] = value
+	}
+	return nil
+}
+
+func (cfg *Config) providerInstance(name string) *ProviderInstance {
+	instances := cfg.Provider.Instance
+	for i := 0; i < len(instances); i++ {
+		if strings.EqualFold(instances[i].Name, name) {
+			return &instances[i]
+		}
+	}
+	cfg.Provider.Instance = append(instances, ProviderInstance{
+		Name: strings.ToLower(name),
+	})
+	return &cfg.Provider.Instance[len(cfg.Provider.Instance)-1]
+}
+
+// Redacted returns a copy of the configuration that secrets are redacted,
+// it is used to print the effective configuration.
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+	c.LogSyslog = redactURL(c.LogSyslog)
+	c.PublicIPv4 = redactPublicIP(cfg.PublicIPv4)
+	c.PublicIPv6 = redactPublicIP(cfg.PublicIPv6)
+	c.Uplink = nil
+	for _, uplink := range cfg.Uplink {
+		uplink.PublicIPv4 = redactPublicIP(uplink.PublicIPv4)
+		uplink.PublicIPv6 = redactPublicIP(uplink.PublicIPv6)
+		c.Uplink = append(c.Uplink, uplink)
+	}
+	c.Provider.ProxyURL = redactURL(c.Provider.ProxyURL)
+	c.Provider.Instance = nil
+	for _, instance := range cfg.Provider.Instance {
+		args := make(map[string]string, len(instance.Args))
+		for k, v := range instance.Args {
+			if isSecretArg(k) {
+				v = redacted
+			}
+			args[k] = v
+		}
+		instance.Args = args
+		c.Provider.Instance = append(c.Provider.Instance, instance)
+	}
+	return &c
+}
+
+func redactPublicIP(p PublicIP) PublicIP {
+	p.URL = redactURL(p.URL)
+	p.ProxyURL = redactURL(p.ProxyURL)
+	p.Source = redactSources(p.Source)
+	return p
+}
+
+func redactSources(sources []SourceConfig) []SourceConfig {
+	if sources == nil {
+		return nil
+	}
+	list := make([]SourceConfig, len(sources))
+	for i, src := range sources {
+		src.URL = redactURL(src.URL)
+		src.ProxyURL = redactURL(src.ProxyURL)
+		if src.Type == sourceUPnP {
+			src.Gateway = redactURL(src.Gateway)
+		}
+		if src.Env != nil {
+			env := make([]string, len(src.Env))
+			for j, e := range src.Env {
+				k, _, ok := strings.Cut(e, "=")
+				if ok && isSecretArg(k) {
+					e = k + "=" + redacted
+				}
+				env[j] = e
+			}
+			src.Env = env
+		}
+		list[i] = src
+	}
+	return list
+}
+
+func redactURL(URL string) string {
+	if URL == "" {
+		return ""
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return URL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,144 @@
+package ddns
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultEventBuffer = 64
+
+// EventType is the type about the event of updater.
+type EventType int
+
+// types about the event.
+const (
+	EventIPDetected EventType = iota + 1
+	EventIPChanged
+	EventPushStarted
+	EventPushSucceeded
+	EventPushFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventIPDetected:
+		return "ip detected"
+	case EventIPChanged:
+		return "ip changed"
+	case EventPushStarted:
+		return "push started"
+	case EventPushSucceeded:
+		return "push succeeded"
+	case EventPushFailed:
+		return "push failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is the event about the update loop, the fields are set by type.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Family is "ipv4" or "ipv6", the uplink is empty if the
+	// address is detected by the default sources.
+	Family string
+	IP     string
+	Uplink string
+
+	// PrevIP is the address detected by the last update, it is empty
+	// at the first update, it is only set by IPChanged.
+	PrevIP string
+
+	// Provider is the item or instance name about the provider.
+	Provider string
+
+	// Err is the reason about PushFailed.
+	Err error
+
+	// Dropped is the number of events that dropped before this event
+	// because the buffer of the subscriber is full.
+	Dropped int
+}
+
+type subscriber struct {
+	ch      chan Event
+	dropped int
+}
+
+// eventBus is used to publish events to subscribers without block,
+// if the buffer of a subscriber is full, the event will be dropped.
+type eventBus struct {
+	subscribers []*subscriber
+	closed      bool
+	mutex       sync.Mutex
+}
+
+func (bus *eventBus) Subscribe(size int) <-chan Event {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	ch := make(chan Event, size)
+	if bus.closed {
+		close(ch)
+		return ch
+	}
+	bus.subscribers = append(bus.subscribers, &subscriber{ch: ch})
+	return ch
+}
+
+func (bus *eventBus) Unsubscribe(ch <-chan Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	for i, sub := range bus.subscribers {
+		if sub.ch != ch {
+			continue
+		}
+		close(sub.ch)
+		bus.subscribers = append(bus.subscribers[:i], bus.subscribers[i+1:]...)
+		return
+	}
+}
+
+func (bus *eventBus) Publish(event Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	for _, sub := range bus.subscribers {
+		e := event
+		e.Dropped = sub.dropped
+		select {
+		case sub.ch <- e:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+func (bus *eventBus) Close() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	for _, sub := range bus.subscribers {
+		close(sub.ch)
+	}
+	bus.subscribers = nil
+	bus.closed = true
+}
+
+// Subscribe returns a channel that receives the events about the update
+// loop, the event will be dropped if the channel buffer is full, so that
+// a slow subscriber will not block the update loop. The channel will be
+// closed after Unsubscribe or Stop.
+func (updater *Updater) Subscribe() <-chan Event {
+	return updater.events.Subscribe(defaultEventBuffer)
+}
+
+// Unsubscribe is used to stop receiving events and close the channel.
+func (updater *Updater) Unsubscribe(ch <-chan Event) {
+	updater.events.Unsubscribe(ch)
+}
+
+func (updater *Updater) publish(event Event) {
+	event.Time = updater.clock.Now()
+	updater.events.Publish(event)
+}
@@ -0,0 +1,109 @@
+package ddns
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		bus := new(eventBus)
+		ch := bus.Subscribe(2)
+
+		for i := 0; i < 5; i++ {
+			bus.Publish(Event{Type: EventIPDetected})
+		}
+		require.Zero(t, (<-ch).Dropped)
+		require.Zero(t, (<-ch).Dropped)
+
+		bus.Publish(Event{Type: EventIPChanged})
+		e := <-ch
+		require.Equal(t, EventIPChanged, e.Type)
+		require.Equal(t, 3, e.Dropped)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		bus := new(eventBus)
+		ch1 := bus.Subscribe(1)
+		ch2 := bus.Subscribe(1)
+
+		bus.Unsubscribe(ch1)
+		_, ok := <-ch1
+		require.False(t, ok)
+
+		bus.Publish(Event{Type: EventIPDetected})
+		require.Equal(t, EventIPDetected, (<-ch2).Type)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		bus := new(eventBus)
+		ch := bus.Subscribe(1)
+
+		bus.Close()
+		_, ok := <-ch
+		require.False(t, ok)
+
+		ch = bus.Subscribe(1)
+		_, ok = <-ch
+		require.False(t, ok)
+	})
+}
+
+func TestEventType_String(t *testing.T) {
+	require.Equal(t, "push failed", EventPushFailed.String())
+	require.Equal(t, "unknown", EventType(0).String())
+}
+
+func TestUpdater_Subscribe(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+
+	ip := "1.2.3.4"
+	source := testRoundTripper(func(*http.Request) (string, error) {
+		return ip, nil
+	})
+	var pushErr error
+	provider := testRoundTripper(func(*http.Request) (string, error) {
+		return "good", pushErr
+	})
+	clock := newTestClock()
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithProviderTransport(provider),
+		WithClock(clock), WithLogger(new(testLogger)),
+	)
+	require.NoError(t, err)
+	events := updater.Subscribe()
+
+	updater.Update()
+	expected := []Event{
+		{Type: EventIPDetected, Family: "ipv4", IP: "1.2.3.4"},
+		{Type: EventIPChanged, Family: "ipv4", IP: "1.2.3.4"},
+		{Type: EventPushStarted, Family: "ipv4", IP: "1.2.3.4", Provider: "test"},
+		{Type: EventPushSucceeded, Family: "ipv4", IP: "1.2.3.4", Provider: "test"},
+	}
+	for i := 0; i < len(expected); i++ {
+		expected[i].Time = clock.Now()
+		require.Equal(t, expected[i], <-events)
+	}
+
+	ip = "1.2.3.5"
+	pushErr = errors.New("connection refused")
+	updater.Update()
+	require.Equal(t, EventIPDetected, (<-events).Type)
+	e := <-events
+	require.Equal(t, EventIPChanged, e.Type)
+	require.Equal(t, "1.2.3.4", e.PrevIP)
+	require.Equal(t, EventPushStarted, (<-events).Type)
+	e = <-events
+	require.Equal(t, EventPushFailed, e.Type)
+	require.ErrorIs(t, e.Err, pushErr)
+
+	updater.Stop()
+	_, ok := <-events
+	require.False(t, ok)
+}
@@ -0,0 +1,118 @@
+package ddns
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var errUpdaterStopped = errors.New("updater is stopped")
+
+// flight is used to serialize the updates, the calls that arrive during
+// an update are merged into one follow-up update and share the result.
+type flight struct {
+	// ctx and wg are about the lifetime of updater, the follow-up
+	// update is run with the ctx and tracked by the wg.
+	ctx context.Context
+	wg  *sync.WaitGroup
+
+	running bool
+	closed  bool
+	idle    chan struct{} // closed when the current update is finished
+	next    *flightCall   // the follow-up update
+	mutex   sync.Mutex
+}
+
+func newFlight(ctx context.Context, wg *sync.WaitGroup) *flight {
+	return &flight{ctx: ctx, wg: wg}
+}
+
+type flightCall struct {
+	done   chan struct{}
+	report *Report
+	err    error
+}
+
+// Do is used to run the update, if an update is running, it will wait
+// for the follow-up update that merged with the other calls. The ctx is
+// only used to stop waiting for the result of the follow-up update, the
+// follow-up update is run with the ctx of flight that is not canceled by
+// any caller, so the other merged calls are not affected by the caller
+// that leaves.
+func (f *flight) Do(ctx context.Context, fn func(ctx context.Context) (*Report, error)) (*Report, error) {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil, errUpdaterStopped
+	}
+	if !f.running {
+		f.running = true
+		f.idle = make(chan struct{})
+		f.mutex.Unlock()
+		return f.run(ctx, fn)
+	}
+	call := f.next
+	if call == nil {
+		// the first merged call will start the follow-up update
+		call = &flightCall{done: make(chan struct{})}
+		f.next = call
+		idle := f.idle
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			<-idle
+			call.report, call.err = f.run(f.ctx, fn)
+			close(call.done)
+		}()
+	}
+	f.mutex.Unlock()
+	select {
+	case <-call.done:
+		return call.report, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TryDo is used to run the update if no update is running,
+// it returns false if the update is skipped.
+func (f *flight) TryDo(ctx context.Context, fn func(ctx context.Context) (*Report, error)) bool {
+	f.mutex.Lock()
+	if f.running || f.closed {
+		f.mutex.Unlock()
+		return false
+	}
+	f.running = true
+	f.idle = make(chan struct{})
+	f.mutex.Unlock()
+	_, _ = f.run(ctx, fn)
+	return true
+}
+
+func (f *flight) run(ctx context.Context, fn func(ctx context.Context) (*Report, error)) (*Report, error) {
+	defer f.finish()
+	return fn(ctx)
+}
+
+// finish will hand off to the follow-up update if it exists,
+// so that the new calls will not run at the same time with it.
+func (f *flight) finish() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	close(f.idle)
+	if f.next == nil {
+		f.running = false
+		return
+	}
+	f.next = nil
+	f.idle = make(chan struct{})
+}
+
+// Close is used to refuse the new updates, it must be called before wait
+// the wg, so the follow-up update will not be added after wait.
+func (f *flight) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.closed = true
+}
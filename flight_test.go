@@ -0,0 +1,224 @@
+package ddns
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlight(t *testing.T) {
+	t.Run("merge", func(t *testing.T) {
+		f := newFlight(context.Background(), new(sync.WaitGroup))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var calls int32
+		fn := func(context.Context) (*Report, error) {
+			n := atomic.AddInt32(&calls, 1)
+			if n == 1 {
+				close(started)
+				<-release
+			}
+			return &Report{Duration: Duration(n)}, nil
+		}
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := f.Do(context.Background(), fn)
+			require.NoError(t, err)
+			require.Equal(t, Duration(1), report.Duration)
+		}()
+		<-started
+
+		reports := make([]*Report, 3)
+		for i := 0; i < len(reports); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				report, err := f.Do(context.Background(), fn)
+				require.NoError(t, err)
+				reports[i] = report
+			}(i)
+		}
+		require.Eventually(t, func() bool {
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+			return f.next != nil
+		}, time.Second, time.Millisecond)
+		require.False(t, f.TryDo(context.Background(), fn))
+
+		// wait the other calls are merged
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		for i := 0; i < len(reports); i++ {
+			require.Equal(t, Duration(2), reports[i].Duration)
+		}
+		require.False(t, f.running)
+		require.True(t, f.TryDo(context.Background(), fn))
+	})
+
+	t.Run("cancel wait", func(t *testing.T) {
+		f := newFlight(context.Background(), new(sync.WaitGroup))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		fn := func(context.Context) (*Report, error) {
+			select {
+			case <-started:
+			default:
+				close(started)
+			}
+			<-release
+			return new(Report), nil
+		}
+		go func() { _, _ = f.Do(context.Background(), fn) }()
+		<-started
+		go func() { _, _ = f.Do(context.Background(), fn) }()
+		require.Eventually(t, func() bool {
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+			return f.next != nil
+		}, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		report, err := f.Do(ctx, fn)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Nil(t, report)
+		close(release)
+	})
+
+	t.Run("cancel first merged", func(t *testing.T) {
+		f := newFlight(context.Background(), new(sync.WaitGroup))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		followed := make(chan error, 1)
+		var calls int32
+		fn := func(ctx context.Context) (*Report, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				<-release
+				return new(Report), nil
+			}
+			followed <- ctx.Err()
+			return new(Report), ctx.Err()
+		}
+		go func() { _, _ = f.Do(context.Background(), fn) }()
+		<-started
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := f.Do(ctx, fn)
+			errCh <- err
+		}()
+		require.Eventually(t, func() bool {
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+			return f.next != nil
+		}, time.Second, time.Millisecond)
+
+		// the first merged call can stop waiting before the update is finished
+		cancel()
+		require.ErrorIs(t, <-errCh, context.Canceled)
+
+		// the follow-up update is not canceled by the first merged call
+		close(release)
+		require.NoError(t, <-followed)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		wg := new(sync.WaitGroup)
+		f := newFlight(ctx, wg)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		followed := make(chan error, 1)
+		var calls int32
+		fn := func(ctx context.Context) (*Report, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				<-release
+				return new(Report), nil
+			}
+			followed <- ctx.Err()
+			return new(Report), ctx.Err()
+		}
+		go func() { _, _ = f.Do(context.Background(), fn) }()
+		<-started
+		go func() { _, _ = f.Do(context.Background(), fn) }()
+		require.Eventually(t, func() bool {
+			f.mutex.Lock()
+			defer f.mutex.Unlock()
+			return f.next != nil
+		}, time.Second, time.Millisecond)
+
+		// the follow-up update is canceled and waited like the updater stopped
+		f.Close()
+		cancel()
+		close(release)
+		wg.Wait()
+		require.ErrorIs(t, <-followed, context.Canceled)
+
+		report, err := f.Do(context.Background(), fn)
+		require.Equal(t, errUpdaterStopped, err)
+		require.Nil(t, report)
+		require.False(t, f.TryDo(context.Background(), fn))
+		require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestUpdater_Trigger(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+
+	source := testRoundTripper(func(*http.Request) (string, error) {
+		return "1.2.3.4", nil
+	})
+	pushed := make(chan struct{}, 4)
+	provider := testRoundTripper(func(*http.Request) (string, error) {
+		pushed <- struct{}{}
+		return "good", nil
+	})
+	clock := newTestClock()
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithProviderTransport(provider),
+		WithClock(clock), WithLogger(new(testLogger)),
+	)
+	require.NoError(t, err)
+
+	// triggers before run are merged
+	updater.Trigger()
+	updater.Trigger()
+	updater.Run()
+	<-pushed
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&clock.ticker.resets) == 1
+	}, time.Second, time.Millisecond)
+
+	// the ticker is reset after manual update
+	updater.Update()
+	<-pushed
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&clock.ticker.resets) == 2
+	}, time.Second, time.Millisecond)
+	clock.Tick()
+	<-pushed
+	updater.Stop()
+
+	require.Empty(t, pushed)
+	require.Equal(t, int32(2), atomic.LoadInt32(&clock.ticker.resets))
+
+	_, err = updater.UpdateContext(context.Background())
+	require.EqualError(t, err, "updater is stopped")
+}
@@ -0,0 +1,70 @@
+//go:build linux
+
+package ddns
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// flags about the route in /proc/net/route.
+const (
+	rtfUp      = 0x1
+	rtfGateway = 0x2
+)
+
+// defaultGateway is used to find the IPv4 default gateway from the routing table.
+func defaultGateway() (net.IP, error) {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	return parseRouteTable(string(data))
+}
+
+// parseRouteTable returns the gateway of the default route with the
+// lowest metric, the addresses in the table are in host byte order.
+func parseRouteTable(table string) (net.IP, error) {
+	var (
+		gateway net.IP
+		metric  int
+	)
+	lines := strings.Split(table, "\n")
+	for i := 1; i < len(lines); i++ {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(lines[i])
+		if len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfUp == 0 || flags&rtfGateway == 0 {
+			continue
+		}
+		addr, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		m, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if gateway != nil && m >= metric {
+			continue
+		}
+		gateway = make(net.IP, net.IPv4len)
+		binary.NativeEndian.PutUint32(gateway, uint32(addr))
+		metric = m
+	}
+	if gateway == nil {
+		return nil, errors.New("no default gateway in routing table")
+	}
+	return gateway, nil
+}
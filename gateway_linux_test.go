@@ -0,0 +1,41 @@
+//go:build linux
+
+package ddns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRouteTable(t *testing.T) {
+	// the addresses in the table are in host byte order
+	hex := func(a, b, c, d byte) string {
+		return fmt.Sprintf("%08X", binary.NativeEndian.Uint32([]byte{a, b, c, d}))
+	}
+	const header = "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	const format = "%s\t%s\t%s\t%s\t0\t0\t%d\t%s\t0\t0\t0\n"
+
+	t.Run("common", func(t *testing.T) {
+		table := header +
+			fmt.Sprintf(format, "eth0", hex(192, 168, 1, 0), "00000000", "0001", 0, hex(255, 255, 255, 0)) +
+			fmt.Sprintf(format, "eth1", "00000000", hex(10, 0, 0, 1), "0003", 200, "00000000") +
+			fmt.Sprintf(format, "eth0", "00000000", hex(192, 168, 1, 1), "0003", 100, "00000000") +
+			fmt.Sprintf(format, "eth2", "00000000", hex(172, 16, 0, 1), "0002", 0, "00000000")
+
+		gateway, err := parseRouteTable(table)
+		require.NoError(t, err)
+		require.Equal(t, "192.168.1.1", gateway.String())
+	})
+
+	t.Run("no default route", func(t *testing.T) {
+		table := header +
+			fmt.Sprintf(format, "eth0", hex(192, 168, 1, 0), "00000000", "0001", 0, hex(255, 255, 255, 0))
+
+		gateway, err := parseRouteTable(table)
+		require.EqualError(t, err, "no default gateway in routing table")
+		require.Nil(t, gateway)
+	})
+}
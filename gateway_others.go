@@ -0,0 +1,15 @@
+//go:build !linux
+
+package ddns
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// defaultGateway is not supported on this platform, the gateway
+// must be set in the source configuration.
+func defaultGateway() (net.IP, error) {
+	return nil, errors.New("default gateway detection is not supported on this platform, set gateway in source")
+}
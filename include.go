@@ -0,0 +1,305 @@
+package ddns
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+)
+
+// configLoader is used to load the configuration file with the files
+// referenced by the include directive, the included files are merged
+// with these rules: tables are merged, lists are appended, the same key
+// with the same value is allowed and the others are reported as conflicts.
+type configLoader struct {
+	data   map[string]interface{}
+	lines  map[string]position
+	loaded map[string]bool
+	stack  []string
+	errs   ValidationErrors
+}
+
+func newConfigLoader() *configLoader {
+	return &configLoader{
+		data:   make(map[string]interface{}),
+		lines:  make(map[string]position),
+		loaded: make(map[string]bool),
+	}
+}
+
+// Load is used to load the configuration file and the included files,
+// it only returns error when failed to read the configuration file, the
+// problems about the included files are stored in the loader.
+func (l *configLoader) Load(path string) error {
+	data, err := os.ReadFile(path) // #nosec
+	if err != nil {
+		return err
+	}
+	l.load(path, data)
+	return nil
+}
+
+func (l *configLoader) load(path string, data []byte) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	l.loaded[abs] = true
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	// decode to the structure for report the unknown fields with line
+	v := newValidator(path)
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(new(Config))
+	if err != nil {
+		v.addDecodeError(err)
+		l.errs = append(l.errs, v.errs...)
+		return
+	}
+	m := make(map[string]interface{})
+	err = toml.Unmarshal(data, &m)
+	if err != nil {
+		v.add("", "%s", err)
+		l.errs = append(l.errs, v.errs...)
+		return
+	}
+	var include []string
+	if list, ok := m["include"].([]interface{}); ok {
+		for _, item := range list {
+			include = append(include, fmt.Sprint(item))
+		}
+	}
+	delete(m, "include")
+	// the relative paths in the included file are relative to itself
+	if len(l.stack) > 1 {
+		resolveIncludedPaths(m, l.includedDir(abs))
+	}
+
+	v.lines = keyPositions(path, data)
+	l.merge(l.data, m, "", v.lines)
+	for key, pos := range v.lines {
+		if _, ok := l.lines[key]; !ok {
+			l.lines[key] = pos
+		}
+	}
+	for _, pattern := range include {
+		l.include(v, pattern)
+	}
+}
+
+// include is used to load the files matched the pattern, the relative
+// pattern is relative to the directory of the including file.
+func (l *configLoader) include(v *validator, pattern string) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(v.file), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		l.addError(v, "invalid pattern \"%s\": %s", pattern, err)
+		return
+	}
+	// a file path without pattern must exist
+	if len(matches) == 0 && !hasGlobMeta(pattern) {
+		l.addError(v, "included file \"%s\" is not exist", pattern)
+		return
+	}
+	sort.Strings(matches)
+	for _, match := range matches {
+		abs, err := filepath.Abs(match)
+		if err != nil {
+			abs = filepath.Clean(match)
+		}
+		if cycle := l.cycle(abs); cycle != "" {
+			l.addError(v, "include cycle: %s", cycle)
+			continue
+		}
+		// skip the file that included by the other file
+		if l.loaded[abs] {
+			continue
+		}
+		stat, err := os.Stat(match)
+		if err == nil && stat.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(match) // #nosec
+		if err != nil {
+			l.addError(v, "failed to read included file: %s", err)
+			continue
+		}
+		l.load(match, data)
+	}
+}
+
+// includedDir returns the directory of the included file that relative to
+// the directory of the main configuration file, the paths in the included
+// file are joined with it, and LoadConfig will resolve them like the main.
+func (l *configLoader) includedDir(abs string) string {
+	dir := filepath.Dir(abs)
+	rel, err := filepath.Rel(filepath.Dir(l.stack[0]), dir)
+	if err != nil {
+		return dir
+	}
+	return rel
+}
+
+// resolveIncludedPaths is used to join the relative provider directory, log
+// file path and exec source directory in the decoded file with the dir.
+func resolveIncludedPaths(m map[string]interface{}, dir string) {
+	resolve := func(m map[string]interface{}, key string) {
+		path, ok := m[key].(string)
+		if ok && path != "" && !filepath.IsAbs(path) {
+			m[key] = filepath.Join(dir, path)
+		}
+	}
+	resolve(m, "log_file")
+	if provider, ok := m["provider"].(map[string]interface{}); ok {
+		resolve(provider, "dir")
+	}
+	sections := []interface{}{m["public_ipv4"], m["public_ipv6"]}
+	if uplinks, ok := m["uplink"].([]interface{}); ok {
+		for _, uplink := range uplinks {
+			if uplink, ok := uplink.(map[string]interface{}); ok {
+				sections = append(sections, uplink["public_ipv4"], uplink["public_ipv6"])
+			}
+		}
+	}
+	for _, section := range sections {
+		section, ok := section.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sources, _ := section["source"].([]interface{})
+		for _, src := range sources {
+			if src, ok := src.(map[string]interface{}); ok {
+				resolve(src, "dir")
+			}
+		}
+	}
+}
+
+// cycle returns the include chain if the file is being loaded.
+func (l *configLoader) cycle(abs string) string {
+	for i := 0; i < len(l.stack); i++ {
+		if l.stack[i] == abs {
+			chain := append(append([]string{}, l.stack[i:]...), abs)
+			return strings.Join(chain, " -> ")
+		}
+	}
+	return ""
+}
+
+func (l *configLoader) addError(v *validator, format string, args ...interface{}) {
+	v.add("include", format, args...)
+	l.errs = append(l.errs, v.errs[len(v.errs)-1])
+}
+
+// merge is used to merge the src to the dst, the lines is the key
+// positions about the file that the src is decoded from.
+func (l *configLoader) merge(dst, src map[string]interface{}, prefix string, lines map[string]position) {
+	keys := make([]string, 0, len(src))
+	for key := range src {
+		keys = append(keys, key)
+	}
+	// make the order of conflicts stable
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		value := src[key]
+		prev, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if prev, ok := prev.(map[string]interface{}); ok {
+				l.merge(prev, value, field, lines)
+				continue
+			}
+		case []interface{}:
+			if prev, ok := prev.([]interface{}); ok {
+				dst[key] = append(prev, value...)
+				continue
+			}
+		default:
+			if reflect.DeepEqual(prev, value) {
+				continue
+			}
+		}
+		l.conflict(field, lines)
+	}
+}
+
+func (l *configLoader) conflict(field string, lines map[string]position) {
+	pos, _ := lookupPosition(lines, field)
+	prev, _ := lookupPosition(l.lines, field)
+	err := ValidationError{
+		File:    pos.file,
+		Line:    pos.line,
+		Field:   field,
+		Message: "conflicts with the value in " + prev.file,
+	}
+	if prev.line > 0 {
+		err.Message = fmt.Sprintf("%s:%d", err.Message, prev.line)
+	}
+	l.errs = append(l.errs, &err)
+}
+
+// Config is used to decode the merged configuration.
+func (l *configLoader) Config() (*Config, error) {
+	data, err := toml.Marshal(l.data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode merged config")
+	}
+	return ParseConfig(bytes.NewReader(data))
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// providerItems returns the provider items in the list and the items
+// matched by the glob patterns, the duplicate items are removed.
+func (cfg *Config) providerItems() ([]string, error) {
+	items := make([]string, 0, len(cfg.Provider.Item))
+	set := make(map[string]bool, len(cfg.Provider.Item))
+	add := func(item string) {
+		if set[item] {
+			return
+		}
+		set[item] = true
+		items = append(items, item)
+	}
+	for _, item := range cfg.Provider.Item {
+		add(item)
+	}
+	for _, pattern := range cfg.Provider.Glob {
+		matches, err := filepath.Glob(filepath.Join(cfg.Provider.Dir, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid provider glob \"%s\"", pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if filepath.Ext(match) != ".toml" {
+				continue
+			}
+			rel, err := filepath.Rel(cfg.Provider.Dir, match)
+			if err != nil {
+				return nil, err
+			}
+			add(strings.TrimSuffix(rel, ".toml"))
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,217 @@
+package ddns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Include(t *testing.T) {
+	t.Run("merge", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.Mkdir(filepath.Join(dir, "conf.d"), 0750)
+		require.NoError(t, err)
+
+		path := testWriteFile(t, dir, "config.toml", `
+include = ["conf.d/*.toml"]
+period  = "5m"
+
+[provider]
+  dir  = "provider"
+  item = ["a"]
+`)
+		testWriteFile(t, dir, "conf.d/10-team1.toml", `
+period = "5m"
+
+[public_ipv4]
+  enabled = true
+
+[provider]
+  item = ["b"]
+
+[[provider.instance]]
+  name     = "home"
+  template = "a"
+`)
+		testWriteFile(t, dir, "conf.d/20-team2.toml", `
+[public_ipv4]
+  url = "https://api.ipify.org/"
+
+[provider]
+  item = ["c"]
+
+[[provider.instance]]
+  name     = "office"
+  template = "b"
+`)
+		testWriteFile(t, dir, "conf.d/README", "not a config")
+
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		require.Equal(t, 5*time.Minute, time.Duration(cfg.Period))
+		require.True(t, cfg.PublicIPv4.Enabled)
+		require.Equal(t, "https://api.ipify.org/", cfg.PublicIPv4.URL)
+		require.Equal(t, []string{"a", "b", "c"}, cfg.Provider.Item)
+		require.Len(t, cfg.Provider.Instance, 2)
+		require.Equal(t, "home", cfg.Provider.Instance[0].Name)
+		require.Equal(t, "office", cfg.Provider.Instance[1].Name)
+		require.Equal(t, filepath.Join(dir, "provider"), cfg.Provider.Dir)
+		require.Empty(t, cfg.Include)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path := testWriteFile(t, dir, "config.toml", "include = [\"a.toml\", \"b.toml\"]\n")
+		testWriteFile(t, dir, "a.toml", "include = [\"b.toml\"]\nlog_tag = \"a\"\n")
+		testWriteFile(t, dir, "b.toml", "[provider]\n  item = [\"b\"]\n")
+
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		require.Equal(t, "a", cfg.LogTag)
+		require.Equal(t, []string{"b"}, cfg.Provider.Item)
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.Mkdir(filepath.Join(dir, "conf.d"), 0750)
+		require.NoError(t, err)
+
+		path := testWriteFile(t, dir, "config.toml", `
+include = ["conf.d/*.toml"]
+
+[provider]
+  item = ["a"]
+`)
+		testWriteFile(t, dir, "conf.d/team.toml", `
+log_file = "team.log"
+
+[[public_ipv4.source]]
+  type    = "exec"
+  command = ["./ip.sh"]
+  dir     = "scripts"
+
+[[uplink]]
+  name = "wan1"
+
+[[uplink.public_ipv6.source]]
+  type    = "exec"
+  command = ["./ip.sh"]
+  dir     = "/opt/scripts"
+`)
+
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+
+		// the paths in the included file are relative to the included file
+		require.Equal(t, filepath.Join(dir, "conf.d", "team.log"), cfg.LogFile)
+		require.Equal(t, filepath.Join(dir, "conf.d", "scripts"), cfg.PublicIPv4.Source[0].Dir)
+		require.Equal(t, "/opt/scripts", cfg.Uplink[0].PublicIPv6.Source[0].Dir)
+		require.Equal(t, filepath.Join(dir, defaultProviderDir), cfg.Provider.Dir)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path := testWriteFile(t, dir, "config.toml", `include = ["other.toml"]
+period = "1m"
+
+[provider]
+  dir = "provider"
+`)
+		other := testWriteFile(t, dir, "other.toml", `period = "2m"
+provider.dir = "foo"
+`)
+
+		_, err := LoadConfig(path)
+		var errs ValidationErrors
+		require.True(t, errors.As(err, &errs))
+		require.Len(t, errs, 2)
+		expected := fmt.Sprintf("%s:1: period: conflicts with the value in %s:2", other, path)
+		require.EqualError(t, errs[0], expected)
+		expected = fmt.Sprintf("%s:2: provider.dir: conflicts with the value in %s:5", other, path)
+		require.EqualError(t, errs[1], expected)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path := testWriteFile(t, dir, "config.toml", "include = [\"a.toml\"]\n")
+		a := testWriteFile(t, dir, "a.toml", "include = [\"config.toml\"]\n")
+
+		_, err := LoadConfig(path)
+		var errs ValidationErrors
+		require.True(t, errors.As(err, &errs))
+		require.Len(t, errs, 1)
+		require.Equal(t, a, errs[0].File)
+		require.Equal(t, 1, errs[0].Line)
+		require.Contains(t, errs[0].Message, "include cycle")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path := testWriteFile(t, dir, "config.toml", "include = [\"a.toml\", \"conf.d/*.toml\"]\n")
+
+		_, err := LoadConfig(path)
+		var errs ValidationErrors
+		require.True(t, errors.As(err, &errs))
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Message, "is not exist")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		dir := t.TempDir()
+
+		path := testWriteFile(t, dir, "config.toml", "include = [\"a.toml\"]\n")
+		a := testWriteFile(t, dir, "a.toml", "\nfoo = 1\n")
+
+		_, err := LoadConfig(path)
+		require.EqualError(t, err, a+":2: foo: unknown field")
+	})
+}
+
+func TestParseConfig_Include(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader("include = [\"a.toml\"]\n"))
+	require.EqualError(t, err, "include directive is only supported by LoadConfig")
+	require.Nil(t, cfg)
+}
+
+func TestConfig_providerItems(t *testing.T) {
+	dir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, "team"), 0750)
+	require.NoError(t, err)
+	testWriteFile(t, dir, "a.toml", "")
+	testWriteFile(t, dir, "b.toml", "")
+	testWriteFile(t, dir, "b.txt", "")
+	testWriteFile(t, dir, "team/c.toml", "")
+
+	t.Run("common", func(t *testing.T) {
+		cfg := new(Config)
+		cfg.Provider.Dir = dir
+		cfg.Provider.Item = []string{"b"}
+		cfg.Provider.Glob = []string{"*", "team/*.toml"}
+
+		items, err := cfg.providerItems()
+		require.NoError(t, err)
+		require.Equal(t, []string{"b", "a", filepath.Join("team", "c")}, items)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		cfg := new(Config)
+		cfg.Provider.Dir = dir
+		cfg.Provider.Glob = []string{"["}
+
+		items, err := cfg.providerItems()
+		require.Error(t, err)
+		require.Nil(t, items)
+	})
+}
@@ -0,0 +1,117 @@
+package ddns
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// policies about the selection of local IPv6 address.
+const policyStable = "stable"
+
+// infiniteLifetime is the preferred lifetime about the address that
+// never expires, like the static address.
+const infiniteLifetime = time.Duration(math.MaxInt64)
+
+// ipv6Addr is the IPv6 address on the local network interface.
+type ipv6Addr struct {
+	ip         net.IP
+	index      int
+	temporary  bool
+	deprecated bool
+	tentative  bool
+
+	// preferred is the remaining preferred lifetime.
+	preferred time.Duration
+}
+
+// selectIPv6 is used to select the stable global address, the temporary
+// address about privacy extensions, the deprecated and tentative address
+// are excluded, the address with longest preferred lifetime is preferred,
+// if the lifetimes are equal, the EUI-64 address is preferred.
+func selectIPv6(addrs []ipv6Addr) net.IP {
+	var candidates []ipv6Addr
+	for _, addr := range addrs {
+		ip := addr.ip
+		if ip.To4() != nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			continue
+		}
+		if addr.temporary || addr.deprecated || addr.tentative || addr.preferred <= 0 {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.preferred != b.preferred {
+			return a.preferred > b.preferred
+		}
+		return isEUI64(a.ip) && !isEUI64(b.ip)
+	})
+	return candidates[0].ip
+}
+
+// isEUI64 is used to check the interface identifier is generated from
+// the MAC address, it has FFFE in the middle.
+func isEUI64(ip net.IP) bool {
+	ip = ip.To16()
+	return ip[11] == 0xFF && ip[12] == 0xFE
+}
+
+// ipv6RouteProbe is a global address that used to find the interface
+// about the default IPv6 route, no packet is sent to it.
+const ipv6RouteProbe = "[2001:4860:4860::8888]:53"
+
+// stableIPv6 returns the stable global IPv6 address on the interface, if
+// the name is empty, the interface about the default route is used, so
+// the address on the docker, VPN and tunnel links is not selected. If
+// failed to read the addresses or not found, it returns nil.
+func stableIPv6(name string) net.IP {
+	if name == "" {
+		var err error
+		name, err = defaultIPv6Interface()
+		if err != nil {
+			return nil
+		}
+	}
+	addrs, err := localIPv6Addrs(name)
+	if err != nil {
+		return nil
+	}
+	return selectIPv6(addrs)
+}
+
+// defaultIPv6Interface returns the name of interface that the system uses
+// to reach the internet over IPv6, it is found by the local address of an
+// unconnected UDP socket that is routed by the system.
+func defaultIPv6Interface() (string, error) {
+	conn, err := net.Dial("udp6", ipv6RouteProbe)
+	if err != nil {
+		return "", errors.Wrap(err, "no default IPv6 route")
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if ok && ipNet.IP.Equal(local) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", errors.Errorf("interface about %s is not found", local)
+}
@@ -0,0 +1,103 @@
+//go:build linux
+
+package ddns
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ifaFlags is the netlink attribute about the extended
+	// address flags, it is not defined in syscall package.
+	ifaFlags = 8
+
+	// ifaInfinityLifetime is the infinite lifetime in ifa_cacheinfo.
+	ifaInfinityLifetime = 0xFFFFFFFF
+)
+
+// localIPv6Addrs is used to read the IPv6 addresses with the flags and
+// lifetime by netlink, if the name is empty, all interfaces are used.
+func localIPv6Addrs(name string) ([]ipv6Addr, error) {
+	index := 0
+	if name != "" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		index = iface.Index
+	}
+	data, err := syscall.NetlinkRIB(syscall.RTM_GETADDR, syscall.AF_INET6)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dump addresses by netlink")
+	}
+	msgs, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse netlink message")
+	}
+	return parseIPv6Addrs(msgs, index)
+}
+
+// parseIPv6Addrs is used to parse the RTM_NEWADDR messages, if the
+// index is not zero, the addresses on other interfaces are skipped.
+func parseIPv6Addrs(msgs []syscall.NetlinkMessage, index int) ([]ipv6Addr, error) {
+	var addrs []ipv6Addr
+	for i := 0; i < len(msgs); i++ {
+		msg := &msgs[i]
+		if msg.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if msg.Header.Type != syscall.RTM_NEWADDR || len(msg.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+		// struct ifaddrmsg: family, prefixlen, flags, scope, index
+		if msg.Data[0] != syscall.AF_INET6 {
+			continue
+		}
+		addr := ipv6Addr{
+			index:     int(binary.NativeEndian.Uint32(msg.Data[4:8])),
+			preferred: infiniteLifetime,
+		}
+		if index != 0 && addr.index != index {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(msg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse netlink attribute")
+		}
+		flags := uint32(msg.Data[2])
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFA_ADDRESS:
+				if len(attr.Value) == net.IPv6len {
+					addr.ip = net.IP(attr.Value)
+				}
+			case syscall.IFA_CACHEINFO:
+				// struct ifa_cacheinfo: preferred, valid, cstamp, tstamp
+				if len(attr.Value) < 4 {
+					continue
+				}
+				preferred := binary.NativeEndian.Uint32(attr.Value[0:4])
+				if preferred != ifaInfinityLifetime {
+					addr.preferred = time.Duration(preferred) * time.Second
+				}
+			case ifaFlags:
+				if len(attr.Value) >= 4 {
+					flags = binary.NativeEndian.Uint32(attr.Value[0:4])
+				}
+			}
+		}
+		if addr.ip == nil {
+			continue
+		}
+		addr.temporary = flags&syscall.IFA_F_TEMPORARY != 0
+		addr.deprecated = flags&syscall.IFA_F_DEPRECATED != 0
+		addr.tentative = flags&(syscall.IFA_F_TENTATIVE|syscall.IFA_F_DADFAILED) != 0
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
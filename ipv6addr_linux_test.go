@@ -0,0 +1,76 @@
+package ddns
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testNetlinkAddr(ip string, index uint32, flags byte, preferred uint32) syscall.NetlinkMessage {
+	data := make([]byte, syscall.SizeofIfAddrmsg)
+	data[0] = syscall.AF_INET6
+	data[1] = 64
+	data[2] = flags
+	binary.NativeEndian.PutUint32(data[4:8], index)
+	// IFA_ADDRESS
+	attr := make([]byte, 4, 4+net.IPv6len)
+	binary.NativeEndian.PutUint16(attr[0:2], uint16(4+net.IPv6len))
+	binary.NativeEndian.PutUint16(attr[2:4], syscall.IFA_ADDRESS)
+	data = append(data, append(attr, net.ParseIP(ip)...)...)
+	// IFA_CACHEINFO
+	attr = make([]byte, 20)
+	binary.NativeEndian.PutUint16(attr[0:2], 20)
+	binary.NativeEndian.PutUint16(attr[2:4], syscall.IFA_CACHEINFO)
+	binary.NativeEndian.PutUint32(attr[4:8], preferred)
+	binary.NativeEndian.PutUint32(attr[8:12], preferred)
+	data = append(data, attr...)
+	msg := syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{Type: syscall.RTM_NEWADDR},
+		Data:   data,
+	}
+	return msg
+}
+
+func TestParseIPv6Addrs(t *testing.T) {
+	msgs := []syscall.NetlinkMessage{
+		testNetlinkAddr("2400:cb00::1", 2, 0, ifaInfinityLifetime),
+		testNetlinkAddr("2400:cb00::a1b2", 2, syscall.IFA_F_TEMPORARY, 3600),
+		testNetlinkAddr("2400:cb00::dead", 2, syscall.IFA_F_DEPRECATED, 0),
+		testNetlinkAddr("2400:cb00:1::1", 3, syscall.IFA_F_TENTATIVE, 60),
+		{Header: syscall.NlMsghdr{Type: syscall.NLMSG_DONE}},
+		testNetlinkAddr("2400:cb00:2::1", 2, 0, 60),
+	}
+
+	addrs, err := parseIPv6Addrs(msgs, 0)
+	require.NoError(t, err)
+	require.Len(t, addrs, 4)
+	require.Equal(t, "2400:cb00::1", addrs[0].ip.String())
+	require.Equal(t, 2, addrs[0].index)
+	require.Equal(t, infiniteLifetime, addrs[0].preferred)
+	require.True(t, addrs[1].temporary)
+	require.Equal(t, time.Hour, addrs[1].preferred)
+	require.True(t, addrs[2].deprecated)
+	require.True(t, addrs[3].tentative)
+
+	addrs, err = parseIPv6Addrs(msgs, 3)
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+	require.Equal(t, "2400:cb00:1::1", addrs[0].ip.String())
+}
+
+func TestLocalIPv6Addrs(t *testing.T) {
+	addrs, err := localIPv6Addrs("")
+	if err != nil {
+		t.Skipf("failed to read addresses by netlink: %s", err)
+	}
+	for _, addr := range addrs {
+		require.Nil(t, addr.ip.To4())
+	}
+
+	_, err = localIPv6Addrs("ddns-not-exist")
+	require.Error(t, err)
+}
@@ -0,0 +1,46 @@
+//go:build !linux
+
+package ddns
+
+import (
+	"net"
+)
+
+// localIPv6Addrs is used to read the IPv6 addresses on the interfaces,
+// the flags and lifetime are unknown, so only the address is used.
+func localIPv6Addrs(name string) ([]ipv6Addr, error) {
+	var ifaces []net.Interface
+	if name != "" {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, *iface)
+	} else {
+		var err error
+		ifaces, err = net.Interfaces()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var addrs []ipv6Addr
+	for _, iface := range ifaces {
+		list, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range list {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || ipNet.IP.To4() != nil {
+				continue
+			}
+			addr := ipv6Addr{
+				ip:        ipNet.IP,
+				index:     iface.Index,
+				preferred: infiniteLifetime,
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
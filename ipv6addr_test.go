@@ -0,0 +1,87 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectIPv6(t *testing.T) {
+	addrs := []ipv6Addr{
+		{ip: net.ParseIP("fe80::1"), preferred: infiniteLifetime},
+		{ip: net.ParseIP("fd00::1"), preferred: infiniteLifetime},
+		{ip: net.ParseIP("2400:cb00::a1b2:c3d4:e5f6:1"), temporary: true, preferred: 2 * time.Hour},
+		{ip: net.ParseIP("2400:cb00::dead"), deprecated: true, preferred: infiniteLifetime},
+		{ip: net.ParseIP("2400:cb00::beef"), tentative: true, preferred: infiniteLifetime},
+		{ip: net.ParseIP("2400:cb00::1"), preferred: time.Hour},
+		{ip: net.ParseIP("2400:cb00::211:22ff:fe33:4455"), preferred: time.Hour},
+	}
+	require.Equal(t, "2400:cb00::211:22ff:fe33:4455", selectIPv6(addrs).String())
+
+	// prefer the longest preferred lifetime
+	addrs = append(addrs, ipv6Addr{ip: net.ParseIP("2400:cb00::2"), preferred: 3 * time.Hour})
+	require.Equal(t, "2400:cb00::2", selectIPv6(addrs).String())
+
+	// the address with zero preferred lifetime is deprecated
+	addrs = []ipv6Addr{{ip: net.ParseIP("2400:cb00::1")}}
+	require.Nil(t, selectIPv6(addrs))
+}
+
+func TestIsEUI64(t *testing.T) {
+	require.True(t, isEUI64(net.ParseIP("2400:cb00::211:22ff:fe33:4455")))
+	require.False(t, isEUI64(net.ParseIP("2400:cb00::1")))
+}
+
+func TestSource_BindStableIPv6(t *testing.T) {
+	cfg := PublicIP{
+		Policy: policyStable,
+		Source: []SourceConfig{
+			{Type: sourceHTTP, URL: "https://api6.ipify.org/"},
+			{Type: sourceHTTP, URL: "https://api6.ipify.org/", LocalAddr: "::1"},
+			{Type: sourceSTUN, Cache: Duration(time.Minute)},
+		},
+	}
+
+	sources, err := newSources(&cfg, false, time.Second, newOptions(nil))
+	require.NoError(t, err)
+	require.NotNil(t, sources[0].(*httpSource).bind)
+	require.NotNil(t, sources[2].(*cachedSource).source.(*stunSource).bind)
+
+	// the local address is preferred than the stable address
+	src := sources[1].(*httpSource)
+	src.bind = func() net.IP { return net.ParseIP("2400:cb00::1") }
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("failed to listen [::1]: %s", err)
+	}
+	defer func() { _ = listener.Close() }()
+	conn, err := src.dialContext(context.Background(), "tcp6", listener.Addr().String())
+	require.NoError(t, err)
+	require.Equal(t, "::1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+	_ = conn.Close()
+
+	// bind the selected address
+	src = sources[0].(*httpSource)
+	src.bind = func() net.IP { return net.IPv6loopback }
+	conn, err = src.dialContext(context.Background(), "tcp6", listener.Addr().String())
+	require.NoError(t, err)
+	require.Equal(t, "::1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+	_ = conn.Close()
+
+	// the address is only selected on the interface
+	require.Nil(t, stableIPv6("ddns-missing0"))
+	name, err := defaultIPv6Interface()
+	if err == nil {
+		_, err = net.InterfaceByName(name)
+		require.NoError(t, err)
+	}
+
+	// not bind the ipv4 sources
+	cfg = PublicIP{Policy: policyStable, URL: "https://api.ipify.org/"}
+	sources, err = newSources(&cfg, true, time.Second, newOptions(nil))
+	require.NoError(t, err)
+	require.Nil(t, sources[0].(*httpSource).bind)
+}
@@ -0,0 +1,104 @@
+package ddns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalOutput is used to send log to journald with the native protocol.
+type journalOutput struct {
+	socket string
+	tag    string
+	pid    string
+
+	conn  *net.UnixConn
+	mutex sync.Mutex
+}
+
+func newJournalOutput(socket, tag string) (*journalOutput, error) {
+	output := journalOutput{
+		socket: socket,
+		tag:    tag,
+		pid:    strconv.Itoa(os.Getpid()),
+	}
+	err := output.connect()
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+func (j *journalOutput) connect() error {
+	addr := net.UnixAddr{
+		Name: j.socket,
+		Net:  "unixgram",
+	}
+	conn, err := net.DialUnix("unixgram", nil, &addr)
+	if err != nil {
+		return err
+	}
+	j.conn = conn
+	return nil
+}
+
+func (j *journalOutput) Log(lv logLevel, msg string) error {
+	buf := bytes.NewBuffer(make([]byte, 0, len(msg)+128))
+	writeJournalField(buf, "MESSAGE", msg)
+	writeJournalField(buf, "PRIORITY", strconv.Itoa(lv.priority()))
+	writeJournalField(buf, "SYSLOG_IDENTIFIER", j.tag)
+	writeJournalField(buf, "SYSLOG_PID", j.pid)
+	writeJournalField(buf, "DDNS_LEVEL", lv.String())
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.conn != nil {
+		_, err := j.conn.Write(buf.Bytes())
+		if err == nil {
+			return nil
+		}
+		_ = j.conn.Close()
+		j.conn = nil
+	}
+	// the socket is recreated after journald restarted, reconnect it
+	err := j.connect()
+	if err != nil {
+		return err
+	}
+	_, err = j.conn.Write(buf.Bytes())
+	return err
+}
+
+// writeJournalField is used to encode field, if the value contains
+// new line, it must be encoded with the binary safe format.
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(value)))
+	buf.Write(size)
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func (j *journalOutput) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
@@ -0,0 +1,73 @@
+//go:build !windows
+
+package ddns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	addr := net.UnixAddr{Name: path, Net: "unixgram"}
+	server, err := net.ListenUnixgram("unixgram", &addr)
+	require.NoError(t, err)
+	defer func() { _ = server.Close() }()
+
+	output, err := newJournalOutput(path, "test")
+	require.NoError(t, err)
+
+	buf := make([]byte, 4096)
+
+	t.Run("common", func(t *testing.T) {
+		err = output.Log(levelWarning, "message")
+		require.NoError(t, err)
+
+		n, err := server.Read(buf)
+		require.NoError(t, err)
+		data := string(buf[:n])
+		require.Contains(t, data, "MESSAGE=message\n")
+		require.Contains(t, data, "PRIORITY=4\n")
+		require.Contains(t, data, "SYSLOG_IDENTIFIER=test\n")
+		require.Contains(t, data, "DDNS_LEVEL=warning\n")
+	})
+
+	t.Run("multi line", func(t *testing.T) {
+		err = output.Log(levelFatal, "panic in func\nfatal")
+		require.NoError(t, err)
+
+		n, err := server.Read(buf)
+		require.NoError(t, err)
+		size := make([]byte, 8)
+		binary.LittleEndian.PutUint64(size, uint64(len("panic in func\nfatal")))
+		expected := "MESSAGE\n" + string(size) + "panic in func\nfatal\n"
+		require.True(t, bytes.HasPrefix(buf[:n], []byte(expected)))
+		require.Contains(t, string(buf[:n]), "PRIORITY=2\n")
+	})
+
+	t.Run("restart", func(t *testing.T) {
+		// journald is restarted and the socket is recreated
+		err := server.Close()
+		require.NoError(t, err)
+		err = os.Remove(path)
+		require.NoError(t, err)
+		server, err = net.ListenUnixgram("unixgram", &addr)
+		require.NoError(t, err)
+
+		err = output.Log(levelInfo, "message")
+		require.NoError(t, err)
+
+		n, err := server.Read(buf)
+		require.NoError(t, err)
+		require.Contains(t, string(buf[:n]), "MESSAGE=message\n")
+	})
+
+	err = output.Close()
+	require.NoError(t, err)
+}
@@ -6,101 +6,208 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultLogTag = "ddns-updater"
+
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelWarning
+	levelError
+	levelFatal
 )
 
+func (lv logLevel) String() string {
+	switch lv {
+	case levelInfo:
+		return "info"
+	case levelWarning:
+		return "warning"
+	case levelError:
+		return "error"
+	case levelFatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// priority is the syslog severity about log level.
+func (lv logLevel) priority() int {
+	switch lv {
+	case levelInfo:
+		return 6
+	case levelWarning:
+		return 4
+	case levelError:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// logOutput is an additional output with log level like journald and syslog.
+type logOutput interface {
+	Log(lv logLevel, msg string) error
+	Close() error
+}
+
 type logger struct {
-	logger *log.Logger
-	file   *os.File
+	logger  *log.Logger
+	file    *rotateFile
+	outputs []logOutput
+	stop    chan struct{}
 }
 
-func newLogger(path string) (*logger, error) {
-	if path == "" {
-		lg := log.New(os.Stdout, "", log.LstdFlags)
-		return &logger{logger: lg}, nil
+func newLogger(cfg *Config) (*logger, error) {
+	tag := cfg.LogTag
+	if tag == "" {
+		tag = defaultLogTag
 	}
-	dir := filepath.Dir(path)
-	if dir != "." {
-		err := os.MkdirAll(dir, 0750)
+	var (
+		writers []io.Writer
+		outputs []logOutput
+		ok      bool
+	)
+	defer func() {
+		if ok {
+			return
+		}
+		for _, output := range outputs {
+			_ = output.Close()
+		}
+	}()
+	if cfg.LogJournald {
+		output, err := newJournalOutput(journalSocket, tag)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect journald")
+		}
+		outputs = append(outputs, output)
+	} else {
+		// the standard output is already captured by journald
+		writers = append(writers, os.Stdout)
+	}
+	if cfg.LogSyslog != "" {
+		output, err := newSyslogOutput(cfg.LogSyslog, tag)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect syslog")
+		}
+		outputs = append(outputs, output)
+	}
+	l := logger{
+		outputs: outputs,
+		stop:    make(chan struct{}),
+	}
+	if cfg.LogFile != "" {
+		rotate := cfg.LogRotate
+		maxSize := int64(rotate.MaxSize) * 1024 * 1024
+		maxAge := time.Duration(rotate.MaxAge)
+		file, err := newRotateFile(cfg.LogFile, maxSize, maxAge, rotate.MaxBackups, rotate.Compress)
 		if err != nil {
 			return nil, err
 		}
+		writers = append(writers, file)
+		l.file = file
+		l.watchReopen()
 	}
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600) // #nosec
+	l.logger = log.New(io.MultiWriter(writers...), "", log.LstdFlags)
+	ok = true
+	return &l, nil
+}
+
+// reopen is used to reopen the log file after it moved by external tool.
+func (l *logger) reopen() {
+	err := l.file.Reopen()
 	if err != nil {
-		return nil, err
+		l.Error("failed to reopen log file:", err)
+		return
 	}
-	lg := log.New(io.MultiWriter(file, os.Stdout), "", log.LstdFlags)
-	return &logger{logger: lg, file: file}, nil
+	l.Info("log file is reopened")
 }
 
 func (l *logger) Info(v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[info] ")
 	_, _ = fmt.Fprintln(buf, v...)
-	l.logger.Print(buf)
+	l.output(levelInfo, buf)
 }
 
 func (l *logger) Infof(format string, v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[info] ")
 	_, _ = fmt.Fprintf(buf, format, v...)
-	l.logger.Println(buf)
+	l.output(levelInfo, buf)
 }
 
 func (l *logger) Warning(v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[warning] ")
 	_, _ = fmt.Fprintln(buf, v...)
-	l.logger.Print(buf)
+	l.output(levelWarning, buf)
 }
 
 func (l *logger) Warningf(format string, v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[warning] ")
 	_, _ = fmt.Fprintf(buf, format, v...)
-	l.logger.Println(buf)
+	l.output(levelWarning, buf)
 }
 
 func (l *logger) Error(v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[error] ")
 	_, _ = fmt.Fprintln(buf, v...)
-	l.logger.Print(buf)
+	l.output(levelError, buf)
 }
 
 func (l *logger) Errorf(format string, v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[error] ")
 	_, _ = fmt.Fprintf(buf, format, v...)
-	l.logger.Println(buf)
+	l.output(levelError, buf)
 }
 
 func (l *logger) Fatal(fn string, v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[fatal] ")
 	buf.WriteString("panic in " + fn + "\n")
 	_, _ = fmt.Fprintln(buf, v...)
-	l.logger.Print(buf)
+	l.output(levelFatal, buf)
 }
 
 func (l *logger) Fatalf(fn, format string, v ...interface{}) {
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
-	buf.WriteString("[fatal] ")
 	buf.WriteString("panic in " + fn + "\n")
 	_, _ = fmt.Fprintf(buf, format, v...)
-	l.logger.Println(buf)
+	l.output(levelFatal, buf)
+}
+
+func (l *logger) output(lv logLevel, buf *bytes.Buffer) {
+	msg := strings.TrimSuffix(buf.String(), "\n")
+	l.logger.Println("[" + lv.String() + "] " + msg)
+	for _, output := range l.outputs {
+		err := output.Log(lv, msg)
+		if err != nil {
+			l.logger.Println("[error] failed to write log to output:", err)
+		}
+	}
 }
 
 func (l *logger) Close() error {
 	var err error
 	if l.file != nil {
+		close(l.stop)
 		err = l.file.Close()
 		if err != nil {
 			l.logger.SetOutput(os.Stderr)
 			l.Error("failed to close log file:", err)
 		}
 	}
+	for _, output := range l.outputs {
+		e := output.Close()
+		if e != nil && err == nil {
+			err = e
+		}
+	}
 	l.logger.SetOutput(io.Discard)
 	return err
 }
@@ -8,7 +8,8 @@ import (
 )
 
 func TestLogger(t *testing.T) {
-	logger, err := newLogger("testdata/testdata.log")
+	cfg := Config{LogFile: "testdata/testdata.log"}
+	logger, err := newLogger(&cfg)
 	require.NoError(t, err)
 	defer func() {
 		err = os.Remove("testdata/testdata.log")
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package ddns
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchReopen will reopen the log file when receive SIGUSR1.
+func (l *logger) watchReopen() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				l.reopen()
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
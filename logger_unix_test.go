@@ -0,0 +1,35 @@
+//go:build !windows
+
+package ddns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	cfg := Config{LogFile: path}
+	logger, err := newLogger(&cfg)
+	require.NoError(t, err)
+
+	logger.Info("before")
+	err = os.Rename(path, path+".1")
+	require.NoError(t, err)
+
+	err = syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(data), "log file is reopened")
+	}, 3*time.Second, 10*time.Millisecond)
+
+	err = logger.Close()
+	require.NoError(t, err)
+}
@@ -0,0 +1,6 @@
+//go:build windows
+
+package ddns
+
+// watchReopen is not supported on windows, because it has no SIGUSR1.
+func (l *logger) watchReopen() {}
@@ -0,0 +1,136 @@
+package ddns
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// notifier is used to send state to systemd with sd_notify protocol.
+type notifier struct {
+	conn     *net.UnixConn
+	watchdog time.Duration
+}
+
+// newNotifier will return nil if NOTIFY_SOCKET is not set.
+func newNotifier() (*notifier, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil, nil
+	}
+	// abstract namespace socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	addr := net.UnixAddr{
+		Name: socket,
+		Net:  "unixgram",
+	}
+	conn, err := net.DialUnix("unixgram", nil, &addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect systemd notify socket")
+	}
+	n := notifier{conn: conn}
+	n.watchdog, err = readWatchdog()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &n, nil
+}
+
+// readWatchdog is used to read the watchdog interval from environment.
+func readWatchdog() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	pid := os.Getenv("WATCHDOG_PID")
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("invalid WATCHDOG_USEC: \"%s\"", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// Notify is used to send states like "READY=1" to systemd.
+func (n *notifier) Notify(state string) error {
+	_, err := n.conn.Write([]byte(state))
+	return err
+}
+
+func (n *notifier) Close() error {
+	return n.conn.Close()
+}
+
+func (updater *Updater) notify(state string) {
+	if updater.notifier == nil {
+		return
+	}
+	err := updater.notifier.Notify(state)
+	if err != nil {
+		updater.logger.Warning("failed to notify systemd:", err)
+	}
+}
+
+// healthy is used to check the update loop is not hung, the longest update
+// contains the detections that all sources of the uplinks are tried in
+// order, two requests for check and push ip, and the verifications about
+// IPv4 and IPv6 records, the domains of a provider are checked and verified
+// one by one.
+func (updater *Updater) healthy() bool {
+	start := atomic.LoadInt64(&updater.updateStart)
+	if start == 0 {
+		return true
+	}
+	limit := 3 * updater.timeout
+	for _, u := range updater.uplinks {
+		limit += u.detectTime
+	}
+	if updater.checker != nil {
+		limit += 2 * time.Duration(updater.maxDomains()) * updater.timeout
+	}
+	if updater.verifier != nil {
+		limit += updater.period
+	}
+	return updater.clock.Now().Sub(time.Unix(0, start)) < limit
+}
+
+// maxDomains returns the most number of domains about a provider, the
+// providers are pushed at the same time, so only the most one is counted.
+func (updater *Updater) maxDomains() int {
+	n := 1
+	for _, provider := range updater.providers {
+		if len(provider.Domains) > n {
+			n = len(provider.Domains)
+		}
+	}
+	return n
+}
+
+// watchdog will send WATCHDOG=1 to systemd when the update loop is healthy,
+// if the update loop is hung, systemd will restart the service.
+func (updater *Updater) watchdog() {
+	defer updater.wg.Done()
+	ticker := updater.clock.NewTicker(updater.notifier.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if !updater.healthy() {
+				updater.logger.Warning("update loop is hung, stop sending watchdog")
+				continue
+			}
+			updater.notify("WATCHDOG=1")
+		case <-updater.ctx.Done():
+			return
+		}
+	}
+}
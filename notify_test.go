@@ -0,0 +1,142 @@
+//go:build !windows
+
+package ddns
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Run("not set", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		n, err := newNotifier()
+		require.NoError(t, err)
+		require.Nil(t, n)
+	})
+
+	t.Run("watchdog", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "notify.sock")
+		server := testListenNotify(t, path)
+		defer func() { _ = server.Close() }()
+		t.Setenv("NOTIFY_SOCKET", path)
+		t.Setenv("WATCHDOG_USEC", "30000000")
+
+		n, err := newNotifier()
+		require.NoError(t, err)
+		require.Equal(t, 30*time.Second, n.watchdog)
+
+		err = n.Notify("READY=1")
+		require.NoError(t, err)
+		require.Equal(t, "READY=1", testReadNotify(t, server))
+
+		err = n.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("watchdog for other process", func(t *testing.T) {
+		t.Setenv("WATCHDOG_USEC", "30000000")
+		t.Setenv("WATCHDOG_PID", fmt.Sprint(os.Getpid()+1))
+
+		interval, err := readWatchdog()
+		require.NoError(t, err)
+		require.Zero(t, interval)
+	})
+
+	t.Run("invalid watchdog", func(t *testing.T) {
+		t.Setenv("WATCHDOG_USEC", "foo")
+
+		interval, err := readWatchdog()
+		require.EqualError(t, err, "invalid WATCHDOG_USEC: \"foo\"")
+		require.Zero(t, interval)
+	})
+}
+
+func TestUpdater_Notify(t *testing.T) {
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("1.2.3.4"))
+	}))
+	defer ipServer.Close()
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("good"))
+	}))
+	defer providerServer.Close()
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	server := testListenNotify(t, path)
+	defer func() { _ = server.Close() }()
+	t.Setenv("NOTIFY_SOCKET", path)
+	t.Setenv("WATCHDOG_USEC", "100000")
+
+	cfg := testProviderConfig(t, providerServer.URL)
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = ipServer.URL
+
+	updater, err := NewUpdater(cfg)
+	require.NoError(t, err)
+
+	updater.Run()
+	updater.Update()
+
+	status := testReadNotify(t, server)
+	require.True(t, strings.HasPrefix(status, "STATUS=ipv4: 1.2.3.4, ipv6: -, last update succeeded at "))
+	require.Equal(t, "READY=1", testReadNotify(t, server))
+	require.Equal(t, "WATCHDOG=1", testReadNotify(t, server))
+
+	// simulate the update loop is hung
+	start := time.Now().Add(-time.Hour).UnixNano()
+	atomic.StoreInt64(&updater.updateStart, start)
+	require.False(t, updater.healthy())
+	atomic.StoreInt64(&updater.updateStart, 0)
+	require.True(t, updater.healthy())
+
+	updater.Stop()
+	for {
+		if testReadNotify(t, server) == "STOPPING=1" {
+			break
+		}
+	}
+
+	// the sources of each uplink are counted
+	updater.uplinks = append(updater.uplinks, &uplink{name: "wan2", detectTime: 5 * time.Minute})
+	start = time.Now().Add(-(5*updater.timeout + 4*time.Minute)).UnixNano()
+	atomic.StoreInt64(&updater.updateStart, start)
+	require.True(t, updater.healthy())
+
+	// the verification is limited in the period
+	updater.verifier = newVerifier(new(Config), updater.clock, nil)
+	updater.providers[0].Domains = []string{"a.example.com", "b.example.com", "c.example.com"}
+	start = time.Now().Add(-(5*updater.timeout + 4*time.Minute + updater.period)).UnixNano()
+	atomic.StoreInt64(&updater.updateStart, start)
+	require.True(t, updater.healthy())
+	start = time.Now().Add(-(5*updater.timeout + 6*time.Minute + updater.period)).UnixNano()
+	atomic.StoreInt64(&updater.updateStart, start)
+	require.False(t, updater.healthy())
+}
+
+func testListenNotify(t *testing.T, path string) *net.UnixConn {
+	addr := net.UnixAddr{Name: path, Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", &addr)
+	require.NoError(t, err)
+	return conn
+}
+
+func testReadNotify(t *testing.T, conn *net.UnixConn) string {
+	err := conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	require.NoError(t, err)
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
@@ -0,0 +1,84 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+// Logger is used to print the log about the updater.
+type Logger interface {
+	Info(v ...interface{})
+	Infof(format string, v ...interface{})
+	Warning(v ...interface{})
+	Warningf(format string, v ...interface{})
+	Error(v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// Resolver is used to find the authoritative name servers about the
+// domain for verify and check records, net.Resolver implements it.
+type Resolver interface {
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Option is used to customize the updater created by NewUpdater.
+type Option func(*options)
+
+type options struct {
+	sourceTransport   http.RoundTripper
+	providerTransport http.RoundTripper
+	clock             Clock
+	resolver          Resolver
+	logger            Logger
+}
+
+func newOptions(opts []Option) *options {
+	o := options{
+		clock:    realClock{},
+		resolver: net.DefaultResolver,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &o
+}
+
+// WithSourceTransport is used to set the transport about the http sources of
+// public IP address, the proxy, local address and interface in config are ignored.
+func WithSourceTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.sourceTransport = rt
+	}
+}
+
+// WithProviderTransport is used to set the transport about the requests
+// to the DDNS providers, the proxy and interface in config are ignored.
+func WithProviderTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.providerTransport = rt
+	}
+}
+
+// WithClock is used to set the clock about the update loop.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithResolver is used to set the resolver for find name servers.
+func WithResolver(resolver Resolver) Option {
+	return func(o *options) {
+		o.resolver = resolver
+	}
+}
+
+// WithLogger is used to set the logger, the log outputs in config are
+// ignored, and the updater will not close it.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
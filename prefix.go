@@ -0,0 +1,60 @@
+package ddns
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const defaultIPv6PrefixLen = 64
+
+// parseInterfaceID is used to parse the interface identifier about the
+// suffix like "::1234" or the modified EUI-64 from the MAC address.
+func parseInterfaceID(suffix, mac string) (net.IP, error) {
+	switch {
+	case suffix != "" && mac != "":
+		return nil, errors.New("ipv6 suffix and mac can not be set at the same time")
+	case suffix != "":
+		ip := net.ParseIP(suffix)
+		if ip == nil || ip.To4() != nil {
+			return nil, errors.Errorf("invalid ipv6 suffix: \"%s\"", suffix)
+		}
+		return ip, nil
+	case mac != "":
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid mac address")
+		}
+		return eui64(hw)
+	default:
+		return nil, nil
+	}
+}
+
+// eui64 is used to generate the interface identifier from the 48-bit MAC
+// address, it inserts FFFE in the middle and flips the universal/local bit.
+func eui64(mac net.HardwareAddr) (net.IP, error) {
+	if len(mac) != 6 {
+		return nil, errors.Errorf("%s is not a 48-bit mac address", mac)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip[8:11], mac[0:3])
+	ip[11] = 0xFF
+	ip[12] = 0xFE
+	copy(ip[13:16], mac[3:6])
+	ip[8] ^= 0x02
+	return ip, nil
+}
+
+// combineIPv6 is used to replace the bits after the prefix of the
+// address with the bits of the interface identifier.
+func combineIPv6(ip net.IP, prefixLen int, id net.IP) net.IP {
+	mask := net.CIDRMask(prefixLen, 8*net.IPv6len)
+	ip = ip.To16()
+	id = id.To16()
+	addr := make(net.IP, net.IPv6len)
+	for i := 0; i < net.IPv6len; i++ {
+		addr[i] = ip[i]&mask[i] | id[i]&^mask[i]
+	}
+	return addr
+}
@@ -0,0 +1,44 @@
+package ddns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterfaceID(t *testing.T) {
+	id, err := parseInterfaceID("::1234", "")
+	require.NoError(t, err)
+	require.Equal(t, "::1234", id.String())
+
+	id, err = parseInterfaceID("", "00:11:22:33:44:55")
+	require.NoError(t, err)
+	require.Equal(t, "::211:22ff:fe33:4455", id.String())
+
+	id, err = parseInterfaceID("", "")
+	require.NoError(t, err)
+	require.Nil(t, id)
+
+	_, err = parseInterfaceID("::1234", "00:11:22:33:44:55")
+	require.EqualError(t, err, "ipv6 suffix and mac can not be set at the same time")
+	_, err = parseInterfaceID("1.2.3.4", "")
+	require.EqualError(t, err, "invalid ipv6 suffix: \"1.2.3.4\"")
+	_, err = parseInterfaceID("", "foo")
+	require.ErrorContains(t, err, "invalid mac address")
+	_, err = parseInterfaceID("", "00:11:22:33:44:55:66:77")
+	require.EqualError(t, err, "00:11:22:33:44:55:66:77 is not a 48-bit mac address")
+}
+
+func TestCombineIPv6(t *testing.T) {
+	ip := net.ParseIP("2400:cb00:1:2:aaaa:bbbb:cccc:dddd")
+
+	addr := combineIPv6(ip, 64, net.ParseIP("::1234"))
+	require.Equal(t, "2400:cb00:1:2::1234", addr.String())
+
+	addr = combineIPv6(ip, 56, net.ParseIP("::3:0:0:0:1"))
+	require.Equal(t, "2400:cb00:1:3::1", addr.String())
+
+	// the original address is not changed
+	require.Equal(t, "2400:cb00:1:2:aaaa:bbbb:cccc:dddd", ip.String())
+}
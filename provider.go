@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,6 +19,11 @@ type provCfg struct {
 		Host     string `toml:"host_url"`
 		Method   string `toml:"method"`
 		Response string `toml:"response"`
+		Domain   string `toml:"domain"`
+
+		// ForceFamily is used to connect the provider with the family of
+		// the pushed address, some servers use the address of client.
+		ForceFamily bool `toml:"force_family"`
 	} `toml:"meta"`
 
 	IPv4 struct {
@@ -32,6 +38,12 @@ type provCfg struct {
 		Skip bool   `toml:"skip"`
 	} `toml:"ipv6"`
 
+	Current struct {
+		Method string `toml:"method"`
+		Path   string `toml:"path"`
+		Body   string `toml:"body"`
+	} `toml:"current"`
+
 	Args map[string]string `toml:"args"`
 }
 
@@ -39,9 +51,25 @@ type provider struct {
 	cfg  *provCfg
 	host *url.URL
 	Resp []string
+
+	// Name is the item or instance name in configuration.
+	Name string
+
+	// Domains is used to verify the record after update.
+	Domains []string
+
+	// uplink is the name about the uplink that provides the address.
+	uplink string
+
+	// ipv6ID is the interface identifier that combined with the
+	// detected IPv6 prefix, if it is nil, the address is not changed.
+	ipv6ID net.IP
 }
 
-func newProvider(r io.Reader) (*provider, error) {
+// newProvider is used to create provider from configuration, the
+// args will override the arguments in the configuration, the name of
+// argument is case-insensitive.
+func newProvider(r io.Reader, args map[string]string) (*provider, error) {
 	d := toml.NewDecoder(r)
 	d.DisallowUnknownFields()
 	cfg := new(provCfg)
@@ -49,28 +77,83 @@ func newProvider(r io.Reader) (*provider, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read provider")
 	}
+	if len(args) != 0 && cfg.Args == nil {
+		cfg.Args = make(map[string]string, len(args))
+	}
+	for k, v := range args {
+		cfg.Args[argName(cfg.Args, k)] = v
+	}
+	host, err := parseProviderHost(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IPv4.Path == "" && cfg.IPv6.Path == "" {
+		return nil, errors.New("IPv4/IPv6 url path are all empty")
+	}
+	var domains []string
+	if cfg.Meta.Domain != "" {
+		tmpl, err := template.New("domain").Parse(cfg.Meta.Domain)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse provider domain")
+		}
+		buf := bytes.NewBuffer(make([]byte, 0, len(cfg.Meta.Domain)))
+		err = tmpl.Execute(buf, cfg.Args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse provider domain arguments")
+		}
+		for _, domain := range strings.Split(buf.String(), ",") {
+			domain = strings.TrimSpace(domain)
+			if domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+	}
+	p := provider{
+		cfg:     cfg,
+		host:    host,
+		Resp:    strings.Split(cfg.Meta.Response, "|"),
+		Domains: domains,
+	}
+	return &p, nil
+}
+
+func parseProviderHost(cfg *provCfg) (*url.URL, error) {
 	tmpl, err := template.New("provider").Parse(cfg.Meta.Host)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse provider http url host")
 	}
-	hostBuf := bytes.NewBuffer(make([]byte, 0, len(cfg.Meta.Host)))
-	err = tmpl.Execute(hostBuf, cfg.Args)
+	buf := bytes.NewBuffer(make([]byte, 0, len(cfg.Meta.Host)))
+	err = tmpl.Execute(buf, cfg.Args)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse provider http url host arguments")
 	}
-	host, err := url.Parse(hostBuf.String())
+	host, err := url.Parse(buf.String())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse host")
 	}
-	if cfg.IPv4.Path == "" && cfg.IPv6.Path == "" {
-		return nil, errors.New("IPv4/IPv6 url path are all empty")
+	return host, nil
+}
+
+// argName returns the name of the existing argument that is equal to
+// the name case-insensitively, if not exists, the name is returned.
+func argName(args map[string]string, name string) string {
+	if _, ok := args[name]; ok {
+		return name
 	}
-	p := provider{
-		cfg:  cfg,
-		host: host,
-		Resp: strings.Split(cfg.Meta.Response, "|"),
+	for k := range args {
+		if strings.EqualFold(k, name) {
+			return k
+		}
 	}
-	return &p, nil
+	return name
+}
+
+// Supports is used to check the provider can update the record about family.
+func (p *provider) Supports(ipv4 bool) bool {
+	if ipv4 {
+		return p.cfg.IPv4.Path != "" && !p.cfg.IPv4.Skip
+	}
+	return p.cfg.IPv6.Path != "" && !p.cfg.IPv6.Skip
 }
 
 func (p *provider) NewIPv4Request(ctx context.Context, ip string) (*http.Request, error) {
@@ -152,3 +235,44 @@ func (p *provider) NewIPv6Request(ctx context.Context, ip string) (*http.Request
 	}
 	return req, nil
 }
+
+// NewCurrentRequest is used to create request for get the current value
+// about the record, it will return nil if the provider not support it.
+func (p *provider) NewCurrentRequest(ctx context.Context) (*http.Request, error) {
+	if p.cfg.Current.Path == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("current").Parse(p.cfg.Current.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse current provider http path")
+	}
+	b := bytes.NewBuffer(make([]byte, 0, len(p.cfg.Current.Path)))
+	err = tmpl.Execute(b, p.cfg.Args)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse current provider http path arguments")
+	}
+	path := b.String()
+	var body io.Reader
+	if p.cfg.Current.Body != "" {
+		tmpl, err = template.New("current").Parse(p.cfg.Current.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse current provider http body")
+		}
+		b = bytes.NewBuffer(make([]byte, 0, len(p.cfg.Current.Body)))
+		err = tmpl.Execute(b, p.cfg.Args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse current provider http body arguments")
+		}
+		body = b
+	}
+	method := p.cfg.Current.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	URL := p.host.String() + path
+	req, err := http.NewRequestWithContext(ctx, method, URL, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build current provider http request")
+	}
+	return req, nil
+}
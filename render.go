@@ -0,0 +1,144 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const redacted = "******"
+
+// secretArgs is the argument name keywords that will be redacted.
+var secretArgs = []string{
+	"pass", "secret", "token", "key", "auth", "credential",
+}
+
+// redacted returns a copy of the provider that the values of secret
+// arguments are replaced, so only the substituted positions are redacted.
+func (p *provider) redacted() (*provider, error) {
+	cfg := *p.cfg
+	cfg.Args = make(map[string]string, len(p.cfg.Args))
+	for name, value := range p.cfg.Args {
+		if value != "" && isSecretArg(name) {
+			value = redacted
+		}
+		cfg.Args[name] = value
+	}
+	host, err := parseProviderHost(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := *p
+	r.cfg = &cfg
+	r.host = host
+	return &r, nil
+}
+
+func isSecretArg(name string) bool {
+	name = strings.ToLower(name)
+	for _, keyword := range secretArgs {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderRequest is used to print the request that created by the redacted
+// provider, the password in the url is also redacted.
+func renderRequest(req *http.Request) (string, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 256))
+	buf.WriteString(req.Method + " " + req.URL.Redacted() + "\n")
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			buf.WriteString(key + ": " + value + "\n")
+		}
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to get request body")
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read request body")
+		}
+		if len(data) != 0 {
+			buf.WriteString("\n")
+			buf.Write(data)
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), nil
+}
+
+// RenderProvider is used to render the requests about the provider
+// with sample IPv4/IPv6 addresses, secrets will be redacted. The name
+// is the provider instance name in configuration, or the provider
+// configuration file name without extension in the provider directory.
+func RenderProvider(cfg *Config, name, ipv4, ipv6 string) (string, error) {
+	for _, instance := range cfg.Provider.Instance {
+		if instance.Name != name {
+			continue
+		}
+		path := filepath.Join(cfg.Provider.Dir, instance.Template)
+		provider, err := loadProvider(path, instance.Args)
+		if err != nil {
+			return "", errors.WithMessagef(err, "failed to load provider instance %s", name)
+		}
+		return provider.Render(ipv4, ipv6)
+	}
+	provider, err := loadProvider(filepath.Join(cfg.Provider.Dir, name), nil)
+	if err != nil {
+		return "", err
+	}
+	return provider.Render(ipv4, ipv6)
+}
+
+// Render is used to render the IPv4/IPv6 requests without send them.
+func (p *provider) Render(ipv4, ipv6 string) (string, error) {
+	ctx := context.Background()
+	p, err := p.redacted()
+	if err != nil {
+		return "", err
+	}
+	var output []string
+	if ipv4 != "" {
+		req, err := p.NewIPv4Request(ctx, ipv4)
+		if err != nil {
+			return "", err
+		}
+		if req != nil {
+			r, err := renderRequest(req)
+			if err != nil {
+				return "", err
+			}
+			output = append(output, "# ipv4\n"+r)
+		}
+	}
+	if ipv6 != "" {
+		req, err := p.NewIPv6Request(ctx, ipv6)
+		if err != nil {
+			return "", err
+		}
+		if req != nil {
+			r, err := renderRequest(req)
+			if err != nil {
+				return "", err
+			}
+			output = append(output, "# ipv6\n"+r)
+		}
+	}
+	return strings.Join(output, "\n"), nil
+}
@@ -0,0 +1,156 @@
+package ddns
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RecordResult is the result about update a record.
+type RecordResult string
+
+// results about update a record.
+const (
+	ResultUpdated  RecordResult = "updated"
+	ResultUpToDate RecordResult = "up-to-date"
+	ResultDryRun   RecordResult = "dry-run"
+	ResultFailed   RecordResult = "failed"
+)
+
+// Report is the report about an update, it can be encoded to JSON.
+type Report struct {
+	Start    time.Time `json:"start"`
+	Duration Duration  `json:"duration"`
+
+	// IPv4 and IPv6 are nil if the family is disabled.
+	IPv4 *Detection `json:"ipv4,omitempty"`
+	IPv6 *Detection `json:"ipv6,omitempty"`
+
+	// Uplinks is the detections about the named uplinks.
+	Uplinks []*UplinkReport `json:"uplinks,omitempty"`
+
+	Records []*RecordReport `json:"records"`
+}
+
+// UplinkReport is the detections about the addresses of a named uplink.
+type UplinkReport struct {
+	Name string     `json:"name"`
+	IPv4 *Detection `json:"ipv4,omitempty"`
+	IPv6 *Detection `json:"ipv6,omitempty"`
+}
+
+// Detection is the result about detect the public address.
+type Detection struct {
+	Source   string   `json:"source"`
+	IP       string   `json:"ip,omitempty"`
+	Duration Duration `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+
+	// CGNAT is true if the address is behind carrier-grade NAT, the
+	// local address is the address of gateway or interface about it,
+	// the records are skipped if the IPv6-only fallback is enabled.
+	CGNAT   bool   `json:"cgnat,omitempty"`
+	LocalIP string `json:"local_ip,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+func (d *Detection) ip() string {
+	if d == nil {
+		return ""
+	}
+	return d.IP
+}
+
+// RecordReport is the result about update the record of a provider with
+// the address family, the domains are empty if the provider not set it.
+type RecordReport struct {
+	Provider string       `json:"provider"`
+	Uplink   string       `json:"uplink,omitempty"`
+	Family   string       `json:"family"`
+	Domains  []string     `json:"domains,omitempty"`
+	IP       string       `json:"ip"`
+	Result   RecordResult `json:"result"`
+	Error    string       `json:"error,omitempty"`
+	Duration Duration     `json:"duration"`
+
+	// about dns propagation verification
+	Propagation Duration `json:"propagation,omitempty"`
+	VerifyError string   `json:"verify_error,omitempty"`
+}
+
+// Failed is used to check the record is failed to update or verify.
+func (r *RecordReport) Failed() bool {
+	return r.Result == ResultFailed || r.VerifyError != ""
+}
+
+func (r *Report) failed() int {
+	var n int
+	for _, record := range r.Records {
+		if record.Failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns the report in text format for print to terminal.
+func (r *Report) String() string {
+	b := new(strings.Builder)
+	type detection struct {
+		family string
+		*Detection
+	}
+	detections := []detection{{"ipv4", r.IPv4}, {"ipv6", r.IPv6}}
+	for _, u := range r.Uplinks {
+		detections = append(detections, detection{u.Name + " ipv4", u.IPv4}, detection{u.Name + " ipv6", u.IPv6})
+	}
+	for _, d := range detections {
+		if d.Detection == nil {
+			continue
+		}
+		elapsed := time.Duration(d.Duration).Round(time.Millisecond)
+		if d.Error != "" {
+			fmt.Fprintf(b, "%s: error: %s (%s in %s)\n", d.family, d.Error, d.Source, elapsed)
+			continue
+		}
+		fmt.Fprintf(b, "%s: %s (%s in %s)", d.family, d.IP, d.Source, elapsed)
+		if d.CGNAT {
+			b.WriteString(", behind cgnat")
+			if d.LocalIP != "" {
+				fmt.Fprintf(b, " with local address %s", d.LocalIP)
+			}
+		}
+		if d.Skipped {
+			b.WriteString(", records skipped")
+		}
+		b.WriteString("\n")
+	}
+	for _, record := range r.Records {
+		name := record.Provider
+		if len(record.Domains) != 0 {
+			name += " " + strings.Join(record.Domains, ",")
+		}
+		if record.Uplink != "" {
+			name += " via " + record.Uplink
+		}
+		elapsed := time.Duration(record.Duration).Round(time.Millisecond)
+		address := record.Family
+		if record.IP != "" {
+			address += " " + record.IP
+		}
+		fmt.Fprintf(b, "%s %s: %s in %s", name, address, record.Result, elapsed)
+		switch {
+		case record.Error != "":
+			fmt.Fprintf(b, ", error: %s", record.Error)
+		case record.VerifyError != "":
+			fmt.Fprintf(b, ", verify error: %s", record.VerifyError)
+		case record.Propagation != 0:
+			propagation := time.Duration(record.Propagation).Round(time.Millisecond)
+			fmt.Fprintf(b, ", propagated in %s", propagation)
+		}
+		b.WriteString("\n")
+	}
+	elapsed := time.Duration(r.Duration).Round(time.Millisecond)
+	fmt.Fprintf(b, "%d records, %d failed, total %s\n", len(r.Records), r.failed(), elapsed)
+	return b.String()
+}
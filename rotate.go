@@ -0,0 +1,296 @@
+package ddns
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// logTimeFormat is the time format at the beginning of log entry.
+const logTimeFormat = "2006/01/02 15:04:05"
+
+// rotateFile is a log file writer that supports size and age based
+// rotation, it also can reopen the file for external tools like logrotate.
+type rotateFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	now func() time.Time
+
+	file   *os.File
+	size   int64
+	openAt time.Time
+	mutex  sync.Mutex
+
+	// cleanMu is used to serialize the compression and removal about
+	// the rotated files, they are processed in background.
+	cleanMu sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func newRotateFile(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*rotateFile, error) {
+	rf := rotateFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+		now:        time.Now,
+	}
+	err := rf.open()
+	if err != nil {
+		return nil, err
+	}
+	return &rf, nil
+}
+
+func (rf *rotateFile) open() error {
+	dir := filepath.Dir(rf.path)
+	if dir != "." {
+		err := os.MkdirAll(dir, 0750)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600) // #nosec
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = stat.Size()
+	rf.openAt = rf.now()
+	if rf.size != 0 {
+		rf.openAt = rf.createdAt(stat)
+	}
+	return nil
+}
+
+// createdAt returns the time about the first entry of the existing log
+// file, so the age is not reset when restart or reopen, if failed to
+// parse the time of entry, the modification time of file is used.
+func (rf *rotateFile) createdAt(stat os.FileInfo) time.Time {
+	file, err := os.Open(rf.path) // #nosec
+	if err != nil {
+		return stat.ModTime()
+	}
+	defer func() { _ = file.Close() }()
+	buf := make([]byte, len(logTimeFormat))
+	_, err = io.ReadFull(file, buf)
+	if err != nil {
+		return stat.ModTime()
+	}
+	t, err := time.ParseInLocation(logTimeFormat, string(buf), time.Local)
+	if err != nil {
+		return stat.ModTime()
+	}
+	return t
+}
+
+// Write implement io.Writer, it will rotate file before write if need.
+func (rf *rotateFile) Write(b []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.needRotate(int64(len(b))) {
+		err := rf.rotate()
+		if err != nil && rf.file == nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(b)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotateFile) needRotate(n int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.maxSize > 0 && rf.size+n > rf.maxSize {
+		return true
+	}
+	if rf.maxAge > 0 && rf.now().Sub(rf.openAt) >= rf.maxAge {
+		return true
+	}
+	return false
+}
+
+// Rotate is used to rotate current log file immediately.
+func (rf *rotateFile) Rotate() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	if rf.file == nil {
+		return os.ErrClosed
+	}
+	return rf.rotate()
+}
+
+func (rf *rotateFile) rotate() error {
+	err := rf.file.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to close log file")
+	}
+	rf.file = nil
+	backup := rf.backupName(rf.now())
+	err = os.Rename(rf.path, backup)
+	if err != nil {
+		// reopen the current file, otherwise the logging is stopped
+		_ = rf.open()
+		return errors.Wrap(err, "failed to rename log file")
+	}
+	err = rf.open()
+	if err != nil {
+		return errors.Wrap(err, "failed to create new log file")
+	}
+	if rf.compress || rf.maxBackups > 0 {
+		rf.wg.Add(1)
+		go rf.clean(backup)
+	}
+	return nil
+}
+
+// clean is used to compress the rotated file and remove the old files,
+// the errors are ignored because the file is the destination of logger.
+func (rf *rotateFile) clean(backup string) {
+	defer rf.wg.Done()
+	rf.cleanMu.Lock()
+	defer rf.cleanMu.Unlock()
+	if rf.compress {
+		_ = compressFile(backup)
+	}
+	_ = rf.removeBackups()
+}
+
+func (rf *rotateFile) backupName(t time.Time) string {
+	dir, name := filepath.Split(rf.path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext)
+	return filepath.Join(dir, prefix+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// backups return the rotated log file list, the newest file is the first.
+func (rf *rotateFile) backups() ([]string, error) {
+	dir, name := filepath.Split(rf.path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		n := entry.Name()
+		if !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(n[len(prefix):], ".gz"), ext)
+		_, err = time.Parse(backupTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(dir, n))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+func (rf *rotateFile) removeBackups() error {
+	if rf.maxBackups < 1 {
+		return nil
+	}
+	files, err := rf.backups()
+	if err != nil {
+		return errors.Wrap(err, "failed to read rotated log files")
+	}
+	if len(files) <= rf.maxBackups {
+		return nil
+	}
+	for _, file := range files[rf.maxBackups:] {
+		err = os.Remove(file)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove rotated log file")
+		}
+	}
+	return nil
+}
+
+// Reopen is used to close and reopen the log file, it is used
+// for external tool that rename the log file like logrotate.
+func (rf *rotateFile) Reopen() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	if rf.file == nil {
+		return os.ErrClosed
+	}
+	err := rf.file.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to close log file")
+	}
+	rf.file = nil
+	return rf.open()
+}
+
+func (rf *rotateFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	rf.wg.Wait()
+	return err
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path) // #nosec
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600) // #nosec
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(dst)
+	_, err = io.Copy(gw, src)
+	if err == nil {
+		err = gw.Close()
+	}
+	if err == nil {
+		err = dst.Close()
+	} else {
+		_ = dst.Close()
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
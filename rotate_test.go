@@ -0,0 +1,200 @@
+package ddns
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateFile(t *testing.T) {
+	t.Run("max size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 16, 0, 0, false)
+		require.NoError(t, err)
+
+		_, err = rf.Write([]byte("0123456789\n"))
+		require.NoError(t, err)
+		_, err = rf.Write([]byte("0123456789\n"))
+		require.NoError(t, err)
+
+		backups, err := rf.backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+
+		data, err := os.ReadFile(backups[0])
+		require.NoError(t, err)
+		require.Equal(t, "0123456789\n", string(data))
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 0, time.Hour, 0, false)
+		require.NoError(t, err)
+		now := time.Now()
+		rf.now = func() time.Time { return now }
+
+		_, err = rf.Write([]byte("old\n"))
+		require.NoError(t, err)
+		now = now.Add(2 * time.Hour)
+		_, err = rf.Write([]byte("new\n"))
+		require.NoError(t, err)
+
+		backups, err := rf.backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "new\n", string(data))
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("max age after reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		now := time.Now()
+		entry := now.Add(-2*time.Hour).Format(logTimeFormat) + " [info] old\n"
+		err := os.WriteFile(path, []byte(entry), 0600)
+		require.NoError(t, err)
+
+		rf, err := newRotateFile(path, 0, time.Hour, 0, false)
+		require.NoError(t, err)
+		_, err = rf.Write([]byte("new\n"))
+		require.NoError(t, err)
+
+		backups, err := rf.backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+
+		// use the modification time if the entry has no time
+		err = os.WriteFile(path, []byte("old\n"), 0600)
+		require.NoError(t, err)
+		err = os.Chtimes(path, now, now.Add(-2*time.Hour))
+		require.NoError(t, err)
+		err = rf.Reopen()
+		require.NoError(t, err)
+		require.Equal(t, now.Add(-2*time.Hour).Unix(), rf.openAt.Unix())
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("max backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 0, 0, 2, false)
+		require.NoError(t, err)
+		now := time.Now()
+		rf.now = func() time.Time { return now }
+
+		for i := 0; i < 5; i++ {
+			_, err = rf.Write([]byte("data\n"))
+			require.NoError(t, err)
+			now = now.Add(time.Second)
+			err = rf.Rotate()
+			require.NoError(t, err)
+		}
+		rf.wg.Wait()
+
+		backups, err := rf.backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 2)
+		require.Equal(t, rf.backupName(now), backups[0])
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("compress", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 0, 0, 0, true)
+		require.NoError(t, err)
+
+		_, err = rf.Write([]byte("compressed\n"))
+		require.NoError(t, err)
+		err = rf.Rotate()
+		require.NoError(t, err)
+		rf.wg.Wait()
+
+		backups, err := rf.backups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		require.True(t, strings.HasSuffix(backups[0], ".log.gz"))
+
+		file, err := os.Open(backups[0])
+		require.NoError(t, err)
+		defer func() { _ = file.Close() }()
+		gr, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		data, err := io.ReadAll(gr)
+		require.NoError(t, err)
+		require.Equal(t, "compressed\n", string(data))
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("rename failed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 16, 0, 0, false)
+		require.NoError(t, err)
+		now := time.Now()
+		rf.now = func() time.Time { return now }
+
+		// the backup path is a directory that is not empty
+		backup := rf.backupName(now)
+		err = os.MkdirAll(filepath.Join(backup, "dir"), 0750)
+		require.NoError(t, err)
+
+		_, err = rf.Write([]byte("0123456789\n"))
+		require.NoError(t, err)
+		_, err = rf.Write([]byte("0123456789\n"))
+		require.NoError(t, err)
+		err = rf.Rotate()
+		require.ErrorContains(t, err, "failed to rename log file")
+
+		// the log file is still writable
+		_, err = rf.Write([]byte("after\n"))
+		require.NoError(t, err)
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "0123456789\n0123456789\nafter\n", string(data))
+
+		err = rf.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "test.log")
+		rf, err := newRotateFile(path, 0, 0, 0, false)
+		require.NoError(t, err)
+
+		_, err = rf.Write([]byte("before\n"))
+		require.NoError(t, err)
+		err = os.Rename(path, path+".1")
+		require.NoError(t, err)
+		err = rf.Reopen()
+		require.NoError(t, err)
+		_, err = rf.Write([]byte("after\n"))
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "after\n", string(data))
+
+		err = rf.Close()
+		require.NoError(t, err)
+
+		_, err = rf.Write([]byte("closed\n"))
+		require.ErrorIs(t, err, os.ErrClosed)
+	})
+}
@@ -0,0 +1,462 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// types about the public IP address source.
+const (
+	sourceHTTP      = "http"
+	sourceDNS       = "dns"
+	sourceSTUN      = "stun"
+	sourceExec      = "exec"
+	sourceInterface = "interface"
+	sourceUPnP      = "upnp"
+	sourceNATPMP    = "natpmp"
+	sourcePCP       = "pcp"
+)
+
+const (
+	defaultUDPSourceTimeout = 3 * time.Second
+	defaultGatewayCache     = 30 * time.Second
+
+	maxSourceResponse = 4096
+)
+
+// source is used to get the public IP address.
+type source interface {
+	Detect(ctx context.Context) (net.IP, error)
+	String() string
+}
+
+// sources returns the source list, if it is empty, the url in
+// the section is used as the http source.
+func (p *PublicIP) sources() []SourceConfig {
+	if len(p.Source) != 0 {
+		return p.Source
+	}
+	src := SourceConfig{
+		Type:      sourceHTTP,
+		URL:       p.URL,
+		ProxyURL:  p.ProxyURL,
+		LocalAddr: p.LocalAddr,
+		Interface: p.Interface,
+	}
+	return []SourceConfig{src}
+}
+
+func newSources(cfg *PublicIP, ipv4 bool, timeout time.Duration, o *options) ([]source, error) {
+	configs := cfg.sources()
+	sources := make([]source, 0, len(configs))
+	for i := 0; i < len(configs); i++ {
+		src, err := newSource(&configs[i], ipv4, timeout, o)
+		if err != nil {
+			return nil, err
+		}
+		cache := time.Duration(configs[i].Cache)
+		if cache == 0 && isGatewaySource(configs[i].Type) {
+			cache = defaultGatewayCache
+		}
+		if cfg.Policy == policyStable && !ipv4 {
+			bindStableIPv6(src)
+		}
+		if cache > 0 {
+			src = &cachedSource{source: src, clock: o.clock, ttl: cache}
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// bindStableIPv6 is used to bind the http and stun sources to the stable
+// IPv6 address that selected when dial, so the detected address is same
+// as the selected address, the source with local address is not changed.
+// The address is selected on the interface of source if it is set, or
+// the interface about the default IPv6 route.
+func bindStableIPv6(src source) {
+	switch s := src.(type) {
+	case *httpSource:
+		s.bind = func() net.IP { return stableIPv6(s.iface) }
+	case *stunSource:
+		s.bind = func() net.IP { return stableIPv6(s.iface) }
+	}
+}
+
+func newSource(cfg *SourceConfig, ipv4 bool, timeout time.Duration, o *options) (source, error) {
+	timeout = sourceTimeout(cfg, timeout)
+	if isGatewaySource(cfg.Type) && !ipv4 {
+		return nil, errors.Errorf("%s source only supports IPv4", cfg.Type)
+	}
+	switch cfg.Type {
+	case "", sourceHTTP:
+		return newHTTPSource(cfg, ipv4, timeout, o.sourceTransport)
+	case sourceDNS:
+		return newDNSSource(cfg, ipv4, timeout)
+	case sourceSTUN:
+		return newSTUNSource(cfg, ipv4, timeout)
+	case sourceExec:
+		return newExecSource(cfg, timeout)
+	case sourceInterface:
+		return newInterfaceSource(cfg, ipv4)
+	case sourceUPnP:
+		return newUPnPSource(cfg, timeout), nil
+	case sourceNATPMP:
+		return newNATPMPSource(cfg, timeout), nil
+	case sourcePCP:
+		return newPCPSource(cfg, timeout), nil
+	default:
+		return nil, errors.Errorf("unsupported source type: \"%s\"", cfg.Type)
+	}
+}
+
+// sourceTimeout returns the timeout about the source, the sources
+// over UDP use the shorter timeout if it is not set.
+func sourceTimeout(cfg *SourceConfig, timeout time.Duration) time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout)
+	}
+	if isGatewaySource(cfg.Type) || cfg.Type == sourceSTUN {
+		return defaultUDPSourceTimeout
+	}
+	return timeout
+}
+
+// isGatewaySource is used to check the source is asking the gateway.
+func isGatewaySource(typ string) bool {
+	switch typ {
+	case sourceUPnP, sourceNATPMP, sourcePCP:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseSourceIP is used to parse the address in the response of source.
+func parseSourceIP(s string) (net.IP, error) {
+	s = strings.TrimSpace(s)
+	ip := net.ParseIP(s)
+	if ip != nil {
+		return ip, nil
+	}
+	if len(s) > 64 {
+		s = s[:64] + "..."
+	}
+	return nil, errors.Errorf("invalid ip address: %q", s)
+}
+
+// checkIPFamily is used to check the address returned by source
+// is in the family, an IPv4-mapped IPv6 address is an IPv4 address.
+func checkIPFamily(ip net.IP, ipv4 bool) error {
+	if ipv4 && ip.To4() == nil {
+		return errors.Errorf("%s is not an IPv4 address", ip)
+	}
+	if !ipv4 && ip.To4() != nil {
+		return errors.Errorf("%s is not an IPv6 address", ip)
+	}
+	return nil
+}
+
+// formats about the output of source.
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// sourceFormat is used to extract the address from the output of source.
+type sourceFormat struct {
+	json   bool
+	key    []string
+	regexp *regexp.Regexp
+}
+
+func newSourceFormat(cfg *SourceConfig) (*sourceFormat, error) {
+	f := sourceFormat{}
+	switch cfg.Format {
+	case "", formatText:
+		if cfg.Key != "" {
+			return nil, errors.New("key is only used with json format")
+		}
+	case formatJSON:
+		f.json = true
+		key := cfg.Key
+		if key == "" {
+			key = "ip"
+		}
+		f.key = strings.Split(key, ".")
+	default:
+		return nil, errors.Errorf("unsupported format: \"%s\"", cfg.Format)
+	}
+	if cfg.Regexp != "" {
+		re, err := regexp.Compile(cfg.Regexp)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid regexp")
+		}
+		f.regexp = re
+	}
+	return &f, nil
+}
+
+// Parse is used to extract the address, the value about the key in json
+// is extracted first, then the regexp is used to find the address in it.
+func (f *sourceFormat) Parse(data []byte) (net.IP, error) {
+	text := string(data)
+	if f.json {
+		var err error
+		text, err = lookupJSONKey(data, f.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.regexp != nil {
+		match := f.regexp.FindStringSubmatch(text)
+		if match == nil {
+			return nil, errors.Errorf("no address is matched by regexp \"%s\"", f.regexp)
+		}
+		text = match[0]
+		if len(match) > 1 {
+			text = match[1]
+		}
+	}
+	return parseSourceIP(text)
+}
+
+// lookupJSONKey returns the string value about the key path in json,
+// the element in array is selected by the index like "list.0.ip".
+func lookupJSONKey(data []byte, key []string) (string, error) {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid json")
+	}
+	for i, k := range key {
+		path := strings.Join(key[:i+1], ".")
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			value, ok = v[k]
+			if !ok {
+				return "", errors.Errorf("key \"%s\" is not found in json", path)
+			}
+		case []interface{}:
+			n, err := strconv.Atoi(k)
+			if err != nil || n < 0 || n >= len(v) {
+				return "", errors.Errorf("index \"%s\" is out of range in json", path)
+			}
+			value = v[n]
+		default:
+			return "", errors.Errorf("key \"%s\" is not found in json", path)
+		}
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.Errorf("value of key \"%s\" is not a string", strings.Join(key, "."))
+	}
+	return s, nil
+}
+
+// httpSource is used to get the address from the response body
+// about the public IP address service.
+type httpSource struct {
+	req     *http.Request
+	client  *http.Client
+	format  *sourceFormat
+	network string
+	proxied bool
+	laddr   *net.TCPAddr
+	iface   string
+
+	// bind returns the local address when dial if laddr is nil.
+	bind func() net.IP
+}
+
+func newHTTPSource(cfg *SourceConfig, ipv4 bool, timeout time.Duration, rt http.RoundTripper) (*httpSource, error) {
+	family := "ipv6"
+	network := "tcp6"
+	if ipv4 {
+		family = "ipv4"
+		network = "tcp4"
+	}
+	req, err := http.NewRequest(http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid url about public %s address provider", family)
+	}
+	format, err := newSourceFormat(cfg)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := readProxyURL(cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{
+		Proxy: proxy,
+	}
+	client := &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+	}
+	if rt != nil {
+		client.Transport = rt
+	}
+	src := httpSource{
+		req:     req,
+		client:  client,
+		format:  format,
+		network: network,
+		proxied: proxy != nil,
+		iface:   cfg.Interface,
+	}
+	tr.DialContext = src.dialContext
+	la := cfg.LocalAddr
+	if la == "" {
+		return &src, nil
+	}
+	if net.ParseIP(la) != nil {
+		la = net.JoinHostPort(la, "0")
+	}
+	lAddr, err := net.ResolveTCPAddr(network, la)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid local %s address", family)
+	}
+	src.laddr = lAddr
+	return &src, nil
+}
+
+// dialContext is used to dial with the family, local address and interface,
+// if failed to connect the proxy with the local address, dial again without
+// it, the connection is always bound to the interface. The family is not
+// forced when connect the proxy, the address that the service can see is
+// about the proxy, so the address in other family is rejected by updater.
+func (s *httpSource) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if !s.proxied {
+		network = s.network
+	}
+	dialer := net.Dialer{}
+	// avoid the typed nil pointer
+	if s.laddr != nil {
+		dialer.LocalAddr = s.laddr
+	} else if s.bind != nil {
+		if ip := s.bind(); ip != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	if s.iface != "" {
+		err := bindInterface(&dialer, s.iface, network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !s.proxied || dialer.LocalAddr == nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+	dialer.LocalAddr = nil
+	return dialer.DialContext(ctx, network, addr)
+}
+
+func (s *httpSource) Detect(ctx context.Context) (net.IP, error) {
+	resp, err := s.client.Do(s.req.Clone(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceResponse))
+	if err != nil {
+		return nil, err
+	}
+	return s.format.Parse(data)
+}
+
+func (s *httpSource) String() string {
+	return redactURL(s.req.URL.String())
+}
+
+// cachedSource is used to reuse the address got from the source
+// until the cache is expired, the failed result is not cached.
+type cachedSource struct {
+	source
+	clock  Clock
+	ttl    time.Duration
+	ip     net.IP
+	expire time.Time
+	mutex  sync.Mutex
+}
+
+func (s *cachedSource) Detect(ctx context.Context) (net.IP, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.clock.Now()
+	if s.ip != nil && now.Before(s.expire) {
+		return s.ip, nil
+	}
+	ip, err := s.source.Detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.ip = ip
+	s.expire = now.Add(s.ttl)
+	return ip, nil
+}
+
+// exchangeUDP is used to send the request until the handler accepts a
+// response, the request is retransmitted with the interval that starts
+// from 250ms and doubles each time, like the client of NAT-PMP and PCP,
+// it returns when the handler accepts a response or the context is done.
+func exchangeUDP(ctx context.Context, conn net.Conn, req []byte, handle func([]byte) (bool, error)) error {
+	// interrupt the blocked read when the context is canceled
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+	buf := make([]byte, 1100)
+	interval := 250 * time.Millisecond
+	for {
+		_, err := conn.Write(req)
+		if err != nil {
+			return err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(interval))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if isTimeout(err) {
+					break
+				}
+				return err
+			}
+			ok, err := handle(buf[:n])
+			if ok {
+				return err
+			}
+		}
+		interval *= 2
+	}
+}
+
+func isTimeout(err error) bool {
+	var e net.Error
+	return errors.As(err, &e) && e.Timeout()
+}
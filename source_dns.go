@@ -0,0 +1,118 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the default dns source is the OpenDNS resolver, it returns the
+// address of client in the answer of the special name.
+const (
+	defaultDNSSourceServer = "resolver1.opendns.com"
+	defaultDNSSourceName   = "myip.opendns.com"
+)
+
+// dnsSource is used to get the address from the answer of dns query,
+// like "myip.opendns.com" A record at OpenDNS resolver or the TXT record
+// of "o-o.myaddr.l.google.com" at the name servers of Google.
+type dnsSource struct {
+	server  string
+	name    string
+	typ     uint16
+	ipv4    bool
+	timeout time.Duration
+	client  *dnsClient
+}
+
+func newDNSSource(cfg *SourceConfig, ipv4 bool, timeout time.Duration) (*dnsSource, error) {
+	server := cfg.Server
+	if server == "" {
+		server = defaultDNSSourceServer
+	}
+	name := cfg.Name
+	if name == "" {
+		name = defaultDNSSourceName
+	}
+	typ := dnsTypeAAAA
+	if ipv4 {
+		typ = dnsTypeA
+	}
+	if cfg.Record != "" {
+		var err error
+		typ, err = parseDNSSourceType(cfg.Record, ipv4)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the query is sent with the transport of the family, then the
+	// server will see the address in the family about the client
+	family := "6"
+	if ipv4 {
+		family = "4"
+	}
+	src := dnsSource{
+		server:  server,
+		name:    name,
+		typ:     typ,
+		ipv4:    ipv4,
+		timeout: timeout,
+		client:  &dnsClient{family: family, iface: cfg.Interface},
+	}
+	return &src, nil
+}
+
+// parseDNSSourceType is used to parse the record type that can be used by
+// the dns source of the family.
+func parseDNSSourceType(record string, ipv4 bool) (uint16, error) {
+	typ, err := parseDNSType(record)
+	if err != nil {
+		return 0, err
+	}
+	family := "IPv6"
+	if ipv4 {
+		family = "IPv4"
+	}
+	switch {
+	case typ == dnsTypeTXT:
+	case typ == dnsTypeA && ipv4:
+	case typ == dnsTypeAAAA && !ipv4:
+	case typ == dnsTypeA || typ == dnsTypeAAAA:
+		return 0, errors.Errorf("%s record can not be used for %s", dnsTypeString(typ), family)
+	default:
+		return 0, errors.Errorf("unsupported record type of dns source: \"%s\"", record)
+	}
+	return typ, nil
+}
+
+func (s *dnsSource) Detect(ctx context.Context) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	values, err := s.client.Lookup(ctx, s.server, s.name, s.typ, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.Errorf("no %s record of %s", dnsTypeString(s.typ), s.name)
+	}
+	// the TXT record may contain the other information like the subnet,
+	// use the first value that is an address in the family
+	for _, value := range values {
+		ip, e := parseSourceIP(strings.Trim(value, "\""))
+		if e == nil {
+			e = checkIPFamily(ip, s.ipv4)
+		}
+		if e == nil {
+			return ip, nil
+		}
+		err = e
+	}
+	return nil, err
+}
+
+func (s *dnsSource) String() string {
+	return sourceDNS + "(" + s.name + " " + dnsTypeString(s.typ) + " @" + s.server + ")"
+}
@@ -0,0 +1,84 @@
+package ddns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSSource(t *testing.T) {
+	server := newTestDNSServer(t)
+	server.Set("myip.opendns.com", dnsTypeA, "1.2.3.4")
+	server.Set("o-o.myaddr.l.google.com", dnsTypeTXT, "edns0-client-subnet 1.2.3.0/24", "1.2.3.5")
+
+	t.Run("default", func(t *testing.T) {
+		src, err := newDNSSource(&SourceConfig{Server: server.Addr()}, true, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, "dns(myip.opendns.com A @"+server.Addr()+")", src.String())
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", ip.String())
+	})
+
+	t.Run("txt", func(t *testing.T) {
+		cfg := SourceConfig{
+			Server: server.Addr(),
+			Name:   "o-o.myaddr.l.google.com",
+			Record: "txt",
+		}
+		src, err := newDNSSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.5", ip.String())
+	})
+
+	t.Run("no record", func(t *testing.T) {
+		cfg := SourceConfig{
+			Server: server.Addr(),
+			Name:   "foo.example.com",
+		}
+		src, err := newDNSSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.EqualError(t, err, "no A record of foo.example.com")
+		require.Nil(t, ip)
+	})
+
+	t.Run("ipv6 transport", func(t *testing.T) {
+		src, err := newDNSSource(&SourceConfig{Server: server.Addr()}, false, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, "dns(myip.opendns.com AAAA @"+server.Addr()+")", src.String())
+
+		// the IPv4 server can not be used with IPv6 transport
+		ip, err := src.Detect(context.Background())
+		require.Error(t, err)
+		require.Nil(t, ip)
+	})
+}
+
+func TestParseDNSSourceType(t *testing.T) {
+	typ, err := parseDNSSourceType("A", true)
+	require.NoError(t, err)
+	require.Equal(t, dnsTypeA, typ)
+	typ, err = parseDNSSourceType("aaaa", false)
+	require.NoError(t, err)
+	require.Equal(t, dnsTypeAAAA, typ)
+	typ, err = parseDNSSourceType("TXT", false)
+	require.NoError(t, err)
+	require.Equal(t, dnsTypeTXT, typ)
+
+	_, err = parseDNSSourceType("AAAA", true)
+	require.EqualError(t, err, "AAAA record can not be used for IPv4")
+	_, err = parseDNSSourceType("A", false)
+	require.EqualError(t, err, "A record can not be used for IPv6")
+	_, err = parseDNSSourceType("NS", true)
+	require.EqualError(t, err, "unsupported record type of dns source: \"NS\"")
+	_, err = parseDNSSourceType("foo", true)
+	require.EqualError(t, err, "unsupported dns record type: \"foo\"")
+}
@@ -0,0 +1,99 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// execSource is used to get the address from the output of the command,
+// it is useful for the router that only provides the address by script.
+type execSource struct {
+	command []string
+	dir     string
+	env     []string
+	timeout time.Duration
+	format  *sourceFormat
+}
+
+func newExecSource(cfg *SourceConfig, timeout time.Duration) (*execSource, error) {
+	if len(cfg.Command) == 0 || cfg.Command[0] == "" {
+		return nil, errors.New("empty command about exec source")
+	}
+	format, err := newSourceFormat(cfg)
+	if err != nil {
+		return nil, err
+	}
+	src := execSource{
+		command: cfg.Command,
+		dir:     cfg.Dir,
+		env:     cfg.Env,
+		timeout: timeout,
+		format:  format,
+	}
+	return &src, nil
+}
+
+// Detect will run the command and parse the address from stdout,
+// the command is killed if it is not exited before timeout.
+func (s *execSource) Detect(ctx context.Context) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...) // #nosec
+	cmd.Dir = s.dir
+	if len(s.env) != 0 {
+		cmd.Env = append(os.Environ(), s.env...)
+	}
+	// not wait the output of the child process forever
+	cmd.WaitDelay = time.Second
+	stdout := bytes.NewBuffer(make([]byte, 0, 64))
+	stderr := bytes.NewBuffer(make([]byte, 0, 64))
+	cmd.Stdout = &limitedWriter{w: stdout, n: maxSourceResponse}
+	cmd.Stderr = &limitedWriter{w: stderr, n: maxSourceResponse}
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, errors.WithMessage(ctx.Err(), "command is killed")
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, errors.Errorf("command exited with code %d", exitErr.ExitCode())
+		}
+		return nil, errors.Errorf("command exited with code %d: %s", exitErr.ExitCode(), msg)
+	}
+	return s.format.Parse(stdout.Bytes())
+}
+
+func (s *execSource) String() string {
+	return sourceExec + "(" + s.command[0] + ")"
+}
+
+// limitedWriter is used to discard the output after n bytes, and it
+// will not return error that will break the command.
+type limitedWriter struct {
+	w *bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	l := len(p)
+	if w.n <= 0 {
+		return l, nil
+	}
+	if len(p) > w.n {
+		p = p[:w.n]
+	}
+	w.w.Write(p)
+	w.n -= len(p)
+	return l, nil
+}
@@ -0,0 +1,95 @@
+package ddns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testExecSource(t *testing.T, cfg *SourceConfig, timeout time.Duration) *execSource {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell is not available on windows")
+	}
+	src, err := newExecSource(cfg, timeout)
+	require.NoError(t, err)
+	return src
+}
+
+func TestExecSource(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		cfg := SourceConfig{Command: []string{"sh", "-c", "echo 1.2.3.4"}}
+		src := testExecSource(t, &cfg, time.Second)
+		require.Equal(t, "exec(sh)", src.String())
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", ip.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cfg := SourceConfig{
+			Command: []string{"sh", "-c", `echo "{\"wan\": {\"ip\": \"$WAN_IP\"}}"`},
+			Env:     []string{"WAN_IP=1.2.3.5"},
+			Format:  formatJSON,
+			Key:     "wan.ip",
+		}
+		src := testExecSource(t, &cfg, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.5", ip.String())
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "ip.txt"), []byte("2400:cb00::1\n"), 0600)
+		require.NoError(t, err)
+		cfg := SourceConfig{
+			Command: []string{"cat", "ip.txt"},
+			Dir:     dir,
+		}
+		src := testExecSource(t, &cfg, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "2400:cb00::1", ip.String())
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		cfg := SourceConfig{Command: []string{"sh", "-c", "echo 1.2.3.4; echo 'no wan' >&2; exit 3"}}
+		src := testExecSource(t, &cfg, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.EqualError(t, err, "command exited with code 3: no wan")
+		require.Nil(t, ip)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		cfg := SourceConfig{Command: []string{"sh", "-c", "sleep 10"}}
+		src := testExecSource(t, &cfg, 100*time.Millisecond)
+
+		ip, err := src.Detect(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, ip)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		cfg := SourceConfig{Command: []string{"ddns-not-exist-command"}}
+		src := testExecSource(t, &cfg, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.Error(t, err)
+		require.Nil(t, ip)
+	})
+
+	t.Run("empty command", func(t *testing.T) {
+		src, err := newExecSource(new(SourceConfig), time.Second)
+		require.EqualError(t, err, "empty command about exec source")
+		require.Nil(t, src)
+	})
+}
@@ -0,0 +1,73 @@
+package ddns
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// interfaceSource is used to get the global address on the local network
+// interface, it is useful when the router delegates an IPv6 prefix and
+// the host has the public address directly.
+type interfaceSource struct {
+	name      string
+	ipv4      bool
+	addrs     func(name string) ([]net.Addr, error)
+	ipv6Addrs func(name string) ([]ipv6Addr, error)
+}
+
+func newInterfaceSource(cfg *SourceConfig, ipv4 bool) (*interfaceSource, error) {
+	if cfg.Interface == "" {
+		return nil, errors.New("empty interface about interface source")
+	}
+	src := interfaceSource{
+		name:      cfg.Interface,
+		ipv4:      ipv4,
+		addrs:     interfaceAddrs,
+		ipv6Addrs: localIPv6Addrs,
+	}
+	return &src, nil
+}
+
+// Detect returns the first global unicast IPv4 address, the IPv6 address
+// is selected by the stable policy, see selectIPv6 for details.
+func (s *interfaceSource) Detect(context.Context) (net.IP, error) {
+	if !s.ipv4 {
+		addrs, err := s.ipv6Addrs(s.name)
+		if err != nil {
+			return nil, err
+		}
+		ip := selectIPv6(addrs)
+		if ip == nil {
+			return nil, errors.Errorf("no stable global IPv6 address on interface %s", s.name)
+		}
+		return ip, nil
+	}
+	addrs, err := s.addrs(s.name)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip, nil
+		}
+	}
+	return nil, errors.Errorf("no global IPv4 address on interface %s", s.name)
+}
+
+func (s *interfaceSource) String() string {
+	return sourceInterface + "(" + s.name + ")"
+}
+
+func interfaceAddrs(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
@@ -0,0 +1,90 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceSource(t *testing.T) {
+	addrs := func(name string) ([]net.Addr, error) {
+		if name != "eth0" {
+			return nil, errors.New("no such network interface")
+		}
+		list := []net.Addr{
+			&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+			&net.IPNet{IP: net.ParseIP("192.168.1.2").To4(), Mask: net.CIDRMask(24, 32)},
+		}
+		return list, nil
+	}
+	ipv6Addrs := func(name string) ([]ipv6Addr, error) {
+		if name != "eth0" {
+			return nil, errors.New("no such network interface")
+		}
+		list := []ipv6Addr{
+			{ip: net.ParseIP("2400:cb00::1234:5678:9abc:def0"), temporary: true, preferred: time.Hour},
+			{ip: net.ParseIP("2400:cb00::2"), preferred: time.Hour},
+		}
+		return list, nil
+	}
+
+	t.Run("ipv6", func(t *testing.T) {
+		src, err := newInterfaceSource(&SourceConfig{Interface: "eth0"}, false)
+		require.NoError(t, err)
+		src.ipv6Addrs = ipv6Addrs
+		require.Equal(t, "interface(eth0)", src.String())
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "2400:cb00::2", ip.String())
+	})
+
+	t.Run("ipv4", func(t *testing.T) {
+		src, err := newInterfaceSource(&SourceConfig{Interface: "eth0"}, true)
+		require.NoError(t, err)
+		src.addrs = addrs
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "192.168.1.2", ip.String())
+	})
+
+	t.Run("no address", func(t *testing.T) {
+		src, err := newInterfaceSource(&SourceConfig{Interface: "lo"}, false)
+		require.NoError(t, err)
+		src.ipv6Addrs = func(string) ([]ipv6Addr, error) {
+			return []ipv6Addr{{ip: net.IPv6loopback, preferred: infiniteLifetime}}, nil
+		}
+
+		ip, err := src.Detect(context.Background())
+		require.EqualError(t, err, "no stable global IPv6 address on interface lo")
+		require.Nil(t, ip)
+
+		src.addrs = func(string) ([]net.Addr, error) {
+			return []net.Addr{&net.IPNet{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(8, 32)}}, nil
+		}
+		src.ipv4 = true
+		ip, err = src.Detect(context.Background())
+		require.EqualError(t, err, "no global IPv4 address on interface lo")
+		require.Nil(t, ip)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		src, err := newInterfaceSource(&SourceConfig{Interface: "ddns-not-exist"}, false)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.Error(t, err)
+		require.Nil(t, ip)
+	})
+
+	t.Run("empty interface", func(t *testing.T) {
+		src, err := newInterfaceSource(new(SourceConfig), false)
+		require.EqualError(t, err, "empty interface about interface source")
+		require.Nil(t, src)
+	})
+}
@@ -0,0 +1,165 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	natpmpPort = "5351"
+
+	// pcpLifetime is the lifetime about the temporary mapping that
+	// used to get the external address, it is deleted after used.
+	pcpLifetime = 60
+)
+
+// natpmpSource is used to get the external IPv4 address of the
+// gateway with NAT-PMP (RFC 6886).
+type natpmpSource struct {
+	gateway string
+	timeout time.Duration
+}
+
+func newNATPMPSource(cfg *SourceConfig, timeout time.Duration) *natpmpSource {
+	return &natpmpSource{
+		gateway: cfg.Gateway,
+		timeout: timeout,
+	}
+}
+
+func (s *natpmpSource) Detect(ctx context.Context) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, err := dialGateway(ctx, s.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	var ip net.IP
+	// version 0, opcode 0 is the external address request
+	err = exchangeUDP(ctx, conn, []byte{0, 0}, func(resp []byte) (bool, error) {
+		if len(resp) < 12 || resp[0] != 0 || resp[1] != 128 {
+			return false, nil
+		}
+		code := binary.BigEndian.Uint16(resp[2:4])
+		if code != 0 {
+			return true, errors.Errorf("nat-pmp request is failed with result code %d", code)
+		}
+		ip = net.IPv4(resp[8], resp[9], resp[10], resp[11])
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ip, nil
+}
+
+func (s *natpmpSource) String() string {
+	if s.gateway == "" {
+		return sourceNATPMP
+	}
+	return sourceNATPMP + "(" + s.gateway + ")"
+}
+
+// pcpSource is used to get the external IPv4 address of the gateway
+// with the assigned address of a PCP MAP request (RFC 6887).
+type pcpSource struct {
+	gateway string
+	timeout time.Duration
+}
+
+func newPCPSource(cfg *SourceConfig, timeout time.Duration) *pcpSource {
+	return &pcpSource{
+		gateway: cfg.Gateway,
+		timeout: timeout,
+	}
+}
+
+func (s *pcpSource) Detect(ctx context.Context) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	conn, err := dialGateway(ctx, s.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	nonce := make([]byte, 12)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	local := conn.LocalAddr().(*net.UDPAddr)
+	req := newPCPMapRequest(local, nonce, pcpLifetime)
+	var ip net.IP
+	err = exchangeUDP(ctx, conn, req, func(resp []byte) (bool, error) {
+		if len(resp) < 60 || resp[0] != 2 || resp[1] != 0x81 {
+			return false, nil
+		}
+		if !bytes.Equal(resp[24:36], nonce) {
+			return false, nil
+		}
+		if resp[3] != 0 {
+			return true, errors.Errorf("pcp request is failed with result code %d", resp[3])
+		}
+		ip = make(net.IP, net.IPv6len)
+		copy(ip, resp[44:60])
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// delete the mapping, the gateway will remove it after lifetime if failed
+	_, _ = conn.Write(newPCPMapRequest(local, nonce, 0))
+	return ip, nil
+}
+
+func (s *pcpSource) String() string {
+	if s.gateway == "" {
+		return sourcePCP
+	}
+	return sourcePCP + "(" + s.gateway + ")"
+}
+
+// newPCPMapRequest is used to create a MAP request about the UDP port
+// of the local address, the zero lifetime is used to delete the mapping.
+func newPCPMapRequest(local *net.UDPAddr, nonce []byte, lifetime uint32) []byte {
+	req := make([]byte, 60)
+	// common request header
+	req[0] = 2 // version
+	req[1] = 1 // opcode MAP
+	binary.BigEndian.PutUint32(req[4:8], lifetime)
+	copy(req[8:24], local.IP.To16())
+	// MAP opcode data
+	copy(req[24:36], nonce)
+	req[36] = 17 // UDP
+	binary.BigEndian.PutUint16(req[40:42], uint16(local.Port))
+	// suggest any external IPv4 address
+	copy(req[44:60], net.IPv4zero.To16())
+	return req
+}
+
+// dialGateway is used to connect the NAT-PMP/PCP server, if the
+// gateway is empty, the default gateway is used.
+func dialGateway(ctx context.Context, gateway string) (net.Conn, error) {
+	addr := gateway
+	if gateway == "" {
+		ip, err := defaultGateway()
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to find default gateway")
+		}
+		addr = net.JoinHostPort(ip.String(), natpmpPort)
+	} else if _, _, err := net.SplitHostPort(gateway); err != nil {
+		addr = net.JoinHostPort(gateway, natpmpPort)
+	}
+	dialer := net.Dialer{}
+	return dialer.DialContext(ctx, "udp4", addr)
+}
@@ -0,0 +1,193 @@
+package ddns
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testUDPServer is used to serve the NAT-PMP/PCP request, it returns
+// the response by function and records the received requests.
+func testUDPServer(t *testing.T, handle func(req []byte) []byte) (string, func() [][]byte) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	var (
+		requests [][]byte
+		mutex    sync.Mutex
+	)
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte{}, buf[:n]...)
+			mutex.Lock()
+			requests = append(requests, req)
+			mutex.Unlock()
+			resp := handle(req)
+			if resp != nil {
+				_, _ = conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() { _ = conn.Close() })
+	get := func() [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([][]byte{}, requests...)
+	}
+	return conn.LocalAddr().String(), get
+}
+
+func TestNATPMPSource(t *testing.T) {
+	t.Run("common", func(t *testing.T) {
+		addr, _ := testUDPServer(t, func(req []byte) []byte {
+			if len(req) != 2 || req[0] != 0 || req[1] != 0 {
+				return nil
+			}
+			resp := make([]byte, 12)
+			resp[1] = 128
+			binary.BigEndian.PutUint32(resp[4:8], 1000)
+			copy(resp[8:12], []byte{1, 2, 3, 4})
+			return resp
+		})
+		src := newNATPMPSource(&SourceConfig{Gateway: addr}, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", ip.String())
+		require.Equal(t, "natpmp("+addr+")", src.String())
+	})
+
+	t.Run("retransmit", func(t *testing.T) {
+		count := 0
+		addr, requests := testUDPServer(t, func([]byte) []byte {
+			count++
+			if count < 2 {
+				return nil
+			}
+			resp := make([]byte, 12)
+			resp[1] = 128
+			copy(resp[8:12], []byte{1, 2, 3, 4})
+			return resp
+		})
+		src := newNATPMPSource(&SourceConfig{Gateway: addr}, 3*time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", ip.String())
+		require.Len(t, requests(), 2)
+	})
+
+	t.Run("result code", func(t *testing.T) {
+		addr, _ := testUDPServer(t, func([]byte) []byte {
+			resp := make([]byte, 12)
+			resp[1] = 128
+			resp[3] = 3
+			return resp
+		})
+		src := newNATPMPSource(&SourceConfig{Gateway: addr}, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.EqualError(t, err, "nat-pmp request is failed with result code 3")
+		require.Nil(t, ip)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		addr, _ := testUDPServer(t, func([]byte) []byte {
+			return nil
+		})
+		src := newNATPMPSource(&SourceConfig{Gateway: addr}, 100*time.Millisecond)
+
+		ip, err := src.Detect(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, ip)
+	})
+}
+
+func TestPCPSource(t *testing.T) {
+	t.Run("common", func(t *testing.T) {
+		addr, requests := testUDPServer(t, func(req []byte) []byte {
+			if len(req) != 60 || req[0] != 2 || req[1] != 1 {
+				return nil
+			}
+			// the delete request has no response
+			if binary.BigEndian.Uint32(req[4:8]) == 0 {
+				return nil
+			}
+			resp := make([]byte, 60)
+			resp[0] = 2
+			resp[1] = 0x81
+			copy(resp[4:8], req[4:8])
+			copy(resp[24:44], req[24:44])
+			copy(resp[44:60], net.IPv4(1, 2, 3, 4).To16())
+			return resp
+		})
+		src := newPCPSource(&SourceConfig{Gateway: addr}, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", ip.String())
+		require.Equal(t, "pcp("+addr+")", src.String())
+
+		// wait the delete request
+		require.Eventually(t, func() bool {
+			return len(requests()) == 2
+		}, time.Second, 10*time.Millisecond)
+		reqs := requests()
+		require.Equal(t, uint32(pcpLifetime), binary.BigEndian.Uint32(reqs[0][4:8]))
+		require.Equal(t, uint32(0), binary.BigEndian.Uint32(reqs[1][4:8]))
+		require.Equal(t, reqs[0][24:36], reqs[1][24:36])
+		require.Equal(t, net.IPv4(127, 0, 0, 1).To16(), net.IP(reqs[0][8:24]))
+		require.Equal(t, byte(17), reqs[0][36])
+	})
+
+	t.Run("invalid nonce", func(t *testing.T) {
+		addr, _ := testUDPServer(t, func(req []byte) []byte {
+			resp := make([]byte, 60)
+			resp[0] = 2
+			resp[1] = 0x81
+			return resp
+		})
+		src := newPCPSource(&SourceConfig{Gateway: addr}, 100*time.Millisecond)
+
+		ip, err := src.Detect(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, ip)
+	})
+
+	t.Run("result code", func(t *testing.T) {
+		addr, _ := testUDPServer(t, func(req []byte) []byte {
+			resp := make([]byte, 60)
+			resp[0] = 2
+			resp[1] = 0x81
+			resp[3] = 8
+			copy(resp[24:36], req[24:36])
+			return resp
+		})
+		src := newPCPSource(&SourceConfig{Gateway: addr}, time.Second)
+
+		ip, err := src.Detect(context.Background())
+		require.EqualError(t, err, "pcp request is failed with result code 8")
+		require.Nil(t, ip)
+	})
+}
+
+func TestDialGateway(t *testing.T) {
+	conn, err := dialGateway(context.Background(), "127.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:5351", conn.RemoteAddr().String())
+	_ = conn.Close()
+
+	conn, err = dialGateway(context.Background(), "127.0.0.1:1234")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:1234", conn.RemoteAddr().String())
+	_ = conn.Close()
+}
@@ -0,0 +1,228 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	stunPort        = "3478"
+	stunMagicCookie = 0x2112A442
+	stunHeaderSize  = 20
+
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunBindingError    = 0x0111
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrErrorCode        = 0x0009
+	stunAttrXORMappedAddress = 0x0020
+)
+
+var defaultSTUNServers = []string{
+	"stun.l.google.com:19302",
+	"stun.cloudflare.com:3478",
+}
+
+// stunSource is used to get the address from the mapped address in the
+// response of STUN binding request (RFC 5389), the request is sent over
+// UDP directly, so it is not affected by the proxy about HTTP.
+type stunSource struct {
+	servers []string
+	network string
+	laddr   *net.UDPAddr
+	iface   string
+	timeout time.Duration
+
+	// bind returns the local address when dial if laddr is nil.
+	bind func() net.IP
+}
+
+func newSTUNSource(cfg *SourceConfig, ipv4 bool, timeout time.Duration) (*stunSource, error) {
+	family := "ipv6"
+	network := "udp6"
+	if ipv4 {
+		family = "ipv4"
+		network = "udp4"
+	}
+	servers := defaultSTUNServers
+	if len(cfg.Servers) != 0 {
+		servers = make([]string, len(cfg.Servers))
+		for i, server := range cfg.Servers {
+			// add the default port if the address has no port
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(strings.Trim(server, "[]"), stunPort)
+			}
+			servers[i] = server
+		}
+	}
+	src := stunSource{
+		servers: servers,
+		network: network,
+		iface:   cfg.Interface,
+		timeout: timeout,
+	}
+	la := cfg.LocalAddr
+	if la == "" {
+		return &src, nil
+	}
+	if net.ParseIP(la) != nil {
+		la = net.JoinHostPort(la, "0")
+	}
+	lAddr, err := net.ResolveUDPAddr(network, la)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid local %s address", family)
+	}
+	src.laddr = lAddr
+	return &src, nil
+}
+
+// Detect will try the servers in order until one of them returns the address.
+func (s *stunSource) Detect(ctx context.Context) (net.IP, error) {
+	var err error
+	for _, server := range s.servers {
+		var ip net.IP
+		ip, err = s.request(ctx, server)
+		if err == nil {
+			return ip, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (s *stunSource) request(ctx context.Context, server string) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	// avoid the typed nil pointer
+	if s.laddr != nil {
+		dialer.LocalAddr = s.laddr
+	} else if s.bind != nil {
+		if ip := s.bind(); ip != nil {
+			dialer.LocalAddr = &net.UDPAddr{IP: ip}
+		}
+	}
+	if s.iface != "" {
+		err := bindInterface(&dialer, s.iface, s.network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	conn, err := dialer.DialContext(ctx, s.network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	_, err = rand.Read(req[8:20])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate transaction id")
+	}
+	var ip net.IP
+	err = exchangeUDP(ctx, conn, req, func(resp []byte) (bool, error) {
+		if len(resp) < stunHeaderSize || !bytes.Equal(resp[4:20], req[4:20]) {
+			return false, nil
+		}
+		var err error
+		ip, err = parseSTUNResponse(resp)
+		return true, err
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to request stun server %s", server)
+	}
+	return ip, nil
+}
+
+// parseSTUNResponse is used to read the mapped address in the response,
+// the XOR-MAPPED-ADDRESS is preferred than the MAPPED-ADDRESS.
+func parseSTUNResponse(resp []byte) (net.IP, error) {
+	typ := binary.BigEndian.Uint16(resp[0:2])
+	size := int(binary.BigEndian.Uint16(resp[2:4]))
+	if stunHeaderSize+size > len(resp) {
+		return nil, errors.New("stun message is too short")
+	}
+	attrs := resp[stunHeaderSize : stunHeaderSize+size]
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrSize := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if 4+attrSize > len(attrs) {
+			return nil, errors.New("stun attribute is too short")
+		}
+		value := attrs[4 : 4+attrSize]
+		switch {
+		case typ == stunBindingError && attrType == stunAttrErrorCode && attrSize >= 4:
+			code := int(value[2]&0x07)*100 + int(value[3])
+			return nil, errors.Errorf("stun error %d: %s", code, value[4:])
+		case typ == stunBindingResponse && attrType == stunAttrXORMappedAddress:
+			return parseSTUNAddress(value, resp[4:20])
+		case typ == stunBindingResponse && attrType == stunAttrMappedAddress:
+			ip, err := parseSTUNAddress(value, nil)
+			if err != nil {
+				return nil, err
+			}
+			mapped = ip
+		}
+		// the attributes are aligned on 32-bit boundaries
+		n := 4 + (attrSize+3)&^3
+		if n > len(attrs) {
+			break
+		}
+		attrs = attrs[n:]
+	}
+	if mapped != nil {
+		return mapped, nil
+	}
+	if typ == stunBindingError {
+		return nil, errors.New("stun binding request is failed")
+	}
+	return nil, errors.New("no mapped address in stun response")
+}
+
+// parseSTUNAddress is used to parse the address attribute value, if the
+// key is not nil, the address is XOR-ed with the magic cookie and the
+// transaction id in it.
+func parseSTUNAddress(value, key []byte) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("invalid stun address attribute")
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, errors.Errorf("unknown stun address family: %d", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, errors.New("invalid stun address attribute")
+	}
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if key != nil {
+		for i := 0; i < size; i++ {
+			ip[i] ^= key[i]
+		}
+	}
+	return ip, nil
+}
+
+func (s *stunSource) String() string {
+	if len(s.servers) == 1 {
+		return sourceSTUN + "(" + s.servers[0] + ")"
+	}
+	return sourceSTUN
+}
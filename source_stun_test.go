@@ -0,0 +1,198 @@
+package ddns
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSTUNServer is used to reply the binding request with the address
+// of client in XOR-MAPPED-ADDRESS, if xor is false, the MAPPED-ADDRESS
+// is used like the server about RFC 3489.
+func testSTUNServer(t *testing.T, network, address string, xor bool) string {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("failed to listen %s: %s", address, err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize || binary.BigEndian.Uint16(buf[0:2]) != stunBindingRequest {
+				continue
+			}
+			resp := testSTUNResponse(buf[:stunHeaderSize], addr.(*net.UDPAddr), xor)
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func testSTUNResponse(req []byte, addr *net.UDPAddr, xor bool) []byte {
+	ip := addr.IP.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = 0x02
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	copy(value[4:], ip)
+	binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port))
+	attrType := uint16(stunAttrMappedAddress)
+	if xor {
+		attrType = stunAttrXORMappedAddress
+		for i := 0; i < len(ip); i++ {
+			value[4+i] ^= req[4+i]
+		}
+		binary.BigEndian.PutUint16(value[2:4], uint16(addr.Port)^uint16(stunMagicCookie>>16))
+	}
+	resp := make([]byte, stunHeaderSize, stunHeaderSize+4+len(value))
+	binary.BigEndian.PutUint16(resp[0:2], stunBindingResponse)
+	binary.BigEndian.PutUint16(resp[2:4], uint16(4+len(value)))
+	copy(resp[4:20], req[4:20])
+	resp = binary.BigEndian.AppendUint16(resp, attrType)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(value)))
+	return append(resp, value...)
+}
+
+func TestSTUNSource(t *testing.T) {
+	t.Run("ipv4", func(t *testing.T) {
+		server := testSTUNServer(t, "udp4", "127.0.0.1:0", true)
+		cfg := SourceConfig{Servers: []string{server}}
+		src, err := newSTUNSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, "stun("+server+")", src.String())
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", ip.String())
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		server := testSTUNServer(t, "udp6", "[::1]:0", true)
+		cfg := SourceConfig{Servers: []string{server}}
+		src, err := newSTUNSource(&cfg, false, time.Second)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "::1", ip.String())
+	})
+
+	t.Run("mapped address", func(t *testing.T) {
+		server := testSTUNServer(t, "udp4", "127.0.0.1:0", false)
+		cfg := SourceConfig{Servers: []string{server}}
+		src, err := newSTUNSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", ip.String())
+	})
+
+	t.Run("local address", func(t *testing.T) {
+		server := testSTUNServer(t, "udp4", "127.0.0.1:0", true)
+		cfg := SourceConfig{
+			Servers:   []string{server},
+			LocalAddr: "127.0.0.2",
+		}
+		src, err := newSTUNSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		if err != nil {
+			t.Skipf("failed to bind local address: %s", err)
+		}
+		require.Equal(t, "127.0.0.2", ip.String())
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		// no response from the first server
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		server := testSTUNServer(t, "udp4", "127.0.0.1:0", true)
+		cfg := SourceConfig{Servers: []string{conn.LocalAddr().String(), server}}
+		src, err := newSTUNSource(&cfg, true, 100*time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, "stun", src.String())
+
+		ip, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", ip.String())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		cfg := SourceConfig{Servers: []string{conn.LocalAddr().String()}}
+		src, err := newSTUNSource(&cfg, true, 100*time.Millisecond)
+		require.NoError(t, err)
+
+		ip, err := src.Detect(context.Background())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, ip)
+	})
+
+	t.Run("default port", func(t *testing.T) {
+		cfg := SourceConfig{Servers: []string{"stun.example.com", "::1"}}
+		src, err := newSTUNSource(&cfg, true, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, []string{"stun.example.com:3478", "[::1]:3478"}, src.servers)
+	})
+}
+
+func TestParseSTUNResponse(t *testing.T) {
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+
+	t.Run("error", func(t *testing.T) {
+		resp := make([]byte, stunHeaderSize)
+		binary.BigEndian.PutUint16(resp[0:2], stunBindingError)
+		binary.BigEndian.PutUint16(resp[2:4], 20)
+		resp = binary.BigEndian.AppendUint16(resp, stunAttrErrorCode)
+		resp = binary.BigEndian.AppendUint16(resp, 15)
+		resp = append(resp, 0, 0, 4, 20)
+		resp = append(resp, "Bad Request"...)
+		resp = append(resp, 0) // padding
+
+		ip, err := parseSTUNResponse(resp)
+		require.EqualError(t, err, "stun error 420: Bad Request")
+		require.Nil(t, ip)
+	})
+
+	t.Run("no address", func(t *testing.T) {
+		resp := make([]byte, stunHeaderSize)
+		binary.BigEndian.PutUint16(resp[0:2], stunBindingResponse)
+
+		ip, err := parseSTUNResponse(resp)
+		require.EqualError(t, err, "no mapped address in stun response")
+		require.Nil(t, ip)
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		resp := testSTUNResponse(req, &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4)}, true)
+
+		ip, err := parseSTUNResponse(resp[:len(resp)-1])
+		require.EqualError(t, err, "stun message is too short")
+		require.Nil(t, ip)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		resp := testSTUNResponse(req, &net.UDPAddr{IP: net.ParseIP("2400:cb00::1")}, true)
+
+		ip, err := parseSTUNResponse(resp)
+		require.NoError(t, err)
+		require.Equal(t, "2400:cb00::1", ip.String())
+	})
+}
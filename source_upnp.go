@@ -0,0 +1,264 @@
+package ddns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const ssdpMulticastAddr = "239.255.255.250:1900"
+
+const ssdpSearchRequest = "M-SEARCH * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"ST: %s\r\n" +
+	"MAN: \"ssdp:discover\"\r\n" +
+	"MX: 2\r\n\r\n"
+
+const soapGetExternalIP = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+	`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:GetExternalIPAddress xmlns:u="%s"></u:GetExternalIPAddress></s:Body>
+</s:Envelope>`
+
+var igdDeviceTypes = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+}
+
+// the services that support GetExternalIPAddress, ordered by preference.
+var wanServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpSource is used to get the external IPv4 address of the
+// gateway with the GetExternalIPAddress action of UPnP IGD.
+type upnpSource struct {
+	location string
+	ssdpAddr string
+	timeout  time.Duration
+	client   *http.Client
+
+	// control is the discovered WAN connection service
+	control *upnpControl
+	mutex   sync.Mutex
+}
+
+type upnpControl struct {
+	url     string
+	service string
+}
+
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// find is used to find the control url about the service in the
+// device and the embedded devices.
+func (d *upnpDevice) find(service string) string {
+	for i := 0; i < len(d.Services); i++ {
+		if d.Services[i].ServiceType == service {
+			return d.Services[i].ControlURL
+		}
+	}
+	for i := 0; i < len(d.Devices); i++ {
+		control := d.Devices[i].find(service)
+		if control != "" {
+			return control
+		}
+	}
+	return ""
+}
+
+func newUPnPSource(cfg *SourceConfig, timeout time.Duration) *upnpSource {
+	client := &http.Client{
+		// the gateway is in the local network, never use proxy
+		Transport: &http.Transport{},
+		Timeout:   timeout,
+	}
+	return &upnpSource{
+		location: cfg.Gateway,
+		ssdpAddr: ssdpMulticastAddr,
+		timeout:  timeout,
+		client:   client,
+	}
+}
+
+func (s *upnpSource) Detect(ctx context.Context) (net.IP, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.control == nil {
+		control, err := s.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.control = control
+	}
+	ip, err := s.getExternalIP(ctx, s.control)
+	if err != nil {
+		// the gateway may be changed, discover it again next time
+		s.control = nil
+		return nil, err
+	}
+	return ip, nil
+}
+
+func (s *upnpSource) discover(ctx context.Context) (*upnpControl, error) {
+	location := s.location
+	if location == "" {
+		var err error
+		location, err = ssdpSearch(ctx, s.ssdpAddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid device description url")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get device description")
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get device description: unexpected status code: %d", resp.StatusCode)
+	}
+	root := upnpRoot{}
+	err = xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&root)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid device description")
+	}
+	base := location
+	if root.URLBase != "" {
+		base = root.URLBase
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base url in device description")
+	}
+	for _, service := range wanServiceTypes {
+		control := root.Device.find(service)
+		if control == "" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(control))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid control url in device description")
+		}
+		c := upnpControl{
+			url:     baseURL.ResolveReference(ref).String(),
+			service: service,
+		}
+		return &c, nil
+	}
+	return nil, errors.New("WAN connection service is not found in device description")
+}
+
+func (s *upnpSource) getExternalIP(ctx context.Context, control *upnpControl) (net.IP, error) {
+	body := fmt.Sprintf(soapGetExternalIP, control.service)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, control.url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=\"utf-8\"")
+	req.Header.Set("SOAPAction", "\""+control.service+"#GetExternalIPAddress\"")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("GetExternalIPAddress is failed with status code: %d", resp.StatusCode)
+	}
+	var envelope struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	err = xml.NewDecoder(io.LimitReader(resp.Body, maxSourceResponse)).Decode(&envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GetExternalIPAddress response")
+	}
+	return parseSourceIP(envelope.IP)
+}
+
+func (s *upnpSource) String() string {
+	if s.location == "" {
+		return sourceUPnP
+	}
+	return sourceUPnP + "(" + redactURL(s.location) + ")"
+}
+
+// ssdpSearch is used to search the internet gateway device in the local
+// network, it returns the location about the first device description.
+func ssdpSearch(ctx context.Context, addr string) (string, error) {
+	rAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid ssdp address")
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+	for _, typ := range igdDeviceTypes {
+		_, err = conn.WriteTo([]byte(fmt.Sprintf(ssdpSearchRequest, typ)), rAddr)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to send ssdp search request")
+		}
+	}
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", errors.New("no UPnP internet gateway device is found")
+			}
+			return "", err
+		}
+		r := bufio.NewReader(bytes.NewReader(buf[:n]))
+		resp, err := http.ReadResponse(r, nil)
+		if err != nil {
+			continue
+		}
+		_ = resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location != "" && strings.Contains(resp.Header.Get("ST"), "InternetGatewayDevice") {
+			return location, nil
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDeviceDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const testExternalIPResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+<s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>%s</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse>
+</s:Body>
+</s:Envelope>`
+
+// testIGDServer is used to serve the device description and the
+// GetExternalIPAddress action like an internet gateway device.
+func testIGDServer(t *testing.T, ip *atomic.Value) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rootDesc.xml":
+			_, _ = w.Write([]byte(testDeviceDescription))
+		case "/ctl/IPConn":
+			action := "\"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress\""
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost || r.Header.Get("SOAPAction") != action ||
+				!strings.Contains(string(body), "GetExternalIPAddress") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = fmt.Fprintf(w, testExternalIPResponse, ip.Load())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testSSDPServer is used to reply the M-SEARCH request with the location.
+func testSSDPServer(t *testing.T, location string) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := string(buf[:n])
+			if !strings.HasPrefix(req, "M-SEARCH * HTTP/1.1\r\n") {
+				continue
+			}
+			// reply a device that is not a gateway at first
+			resp := "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\nLOCATION: http://127.0.0.1:1/\r\n\r\n"
+			_, _ = conn.WriteTo([]byte(resp), addr)
+			resp = "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\n" +
+				"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+				"LOCATION: " + location + "\r\n\r\n"
+			_, _ = conn.WriteTo([]byte(resp), addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUPnPSource(t *testing.T) {
+	ip := new(atomic.Value)
+	ip.Store("1.2.3.4")
+	igd := testIGDServer(t, ip)
+
+	t.Run("discover", func(t *testing.T) {
+		src := newUPnPSource(new(SourceConfig), time.Second)
+		src.ssdpAddr = testSSDPServer(t, igd.URL+"/rootDesc.xml")
+
+		addr, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", addr.String())
+		require.Equal(t, igd.URL+"/ctl/IPConn", src.control.url)
+		require.Equal(t, "upnp", src.String())
+
+		// use the cached control url
+		src.ssdpAddr = "127.0.0.1:1"
+		ip.Store("1.2.3.5")
+		defer ip.Store("1.2.3.4")
+		addr, err = src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.5", addr.String())
+	})
+
+	t.Run("location", func(t *testing.T) {
+		src := newUPnPSource(&SourceConfig{Gateway: igd.URL + "/rootDesc.xml"}, time.Second)
+
+		addr, err := src.Detect(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "1.2.3.4", addr.String())
+		require.Equal(t, "upnp("+igd.URL+"/rootDesc.xml)", src.String())
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		src := newUPnPSource(&SourceConfig{Gateway: igd.URL + "/rootDesc.xml"}, time.Second)
+		ip.Store("")
+		defer ip.Store("1.2.3.4")
+
+		addr, err := src.Detect(context.Background())
+		require.EqualError(t, err, "invalid ip address: \"\"")
+		require.Nil(t, addr)
+		require.Nil(t, src.control)
+	})
+
+	t.Run("no service", func(t *testing.T) {
+		src := newUPnPSource(&SourceConfig{Gateway: igd.URL + "/foo"}, time.Second)
+
+		addr, err := src.Detect(context.Background())
+		require.EqualError(t, err, "failed to get device description: unexpected status code: 404")
+		require.Nil(t, addr)
+	})
+
+	t.Run("no device", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		src := newUPnPSource(new(SourceConfig), 100*time.Millisecond)
+		src.ssdpAddr = conn.LocalAddr().String()
+
+		addr, err := src.Detect(context.Background())
+		require.EqualError(t, err, "no UPnP internet gateway device is found")
+		require.Nil(t, addr)
+	})
+}
+
+func TestUpnpDevice_find(t *testing.T) {
+	device := upnpDevice{}
+	device.Devices = make([]upnpDevice, 1)
+	device.Devices[0].Services = []upnpService{{
+		ServiceType: "urn:schemas-upnp-org:service:WANPPPConnection:1",
+		ControlURL:  "/ctl/PPPConn",
+	}}
+
+	require.Equal(t, "/ctl/PPPConn", device.find("urn:schemas-upnp-org:service:WANPPPConnection:1"))
+	require.Empty(t, device.find("urn:schemas-upnp-org:service:WANIPConnection:1"))
+}
@@ -0,0 +1,65 @@
+package ddns
+
+import (
+	"fmt"
+	"time"
+)
+
+// status contains the result about the last update.
+type status struct {
+	IPv4    string
+	IPv6    string
+	Success bool
+	Time    time.Time
+
+	// CGNAT is true if the IPv4 address is behind carrier-grade NAT,
+	// IPv4Skipped is true if the IPv4 records are not updated about it.
+	CGNAT       bool
+	IPv4Skipped bool
+
+	// about dns propagation verification
+	Propagated  int
+	Mismatched  int
+	Propagation time.Duration
+}
+
+func (s *status) String() string {
+	result := "failed"
+	if s.Success {
+		result = "succeeded"
+	}
+	ipv4 := s.IPv4
+	switch {
+	case ipv4 == "":
+		ipv4 = "-"
+	case s.IPv4Skipped:
+		ipv4 += " (cgnat, skipped)"
+	case s.CGNAT:
+		ipv4 += " (cgnat)"
+	}
+	ipv6 := s.IPv6
+	if ipv6 == "" {
+		ipv6 = "-"
+	}
+	const format = "ipv4: %s, ipv6: %s, last update %s at %s"
+	str := fmt.Sprintf(format, ipv4, ipv6, result, s.Time.Format(time.RFC3339))
+	if s.Propagated == 0 && s.Mismatched == 0 {
+		return str
+	}
+	const verify = ", propagated: %d, mismatched: %d, last propagation: %s"
+	return str + fmt.Sprintf(verify, s.Propagated, s.Mismatched, s.Propagation.Round(time.Millisecond))
+}
+
+// notifyStatus is used to update the status and notify it to systemd,
+// it will send READY=1 after the first update cycle.
+func (updater *Updater) notifyStatus(ok bool) {
+	updater.statusMu.Lock()
+	updater.status.Success = ok
+	updater.status.Time = updater.clock.Now()
+	state := "STATUS=" + updater.status.String()
+	updater.statusMu.Unlock()
+	updater.notify(state)
+	updater.readyOnce.Do(func() {
+		updater.notify("READY=1")
+	})
+}
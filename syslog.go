@@ -0,0 +1,208 @@
+package ddns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// facility daemon
+const syslogFacility = 3
+
+// the log line is dropped if the syslog server is stalled, and the
+// reconnection is delayed after failed to avoid blocking each line.
+const (
+	syslogTimeout    = 3 * time.Second
+	syslogMinBackoff = time.Second
+	syslogMaxBackoff = time.Minute
+)
+
+var localSyslogSockets = []string{
+	"/dev/log",
+	"/var/run/syslog",
+	"/var/run/log",
+}
+
+// syslogOutput is used to send log to the local syslog daemon
+// or the remote syslog server with RFC 5424 over UDP/TCP/TLS.
+type syslogOutput struct {
+	network string
+	address string
+	local   bool
+	tag     string
+	host    string
+	pid     int
+
+	tlsConfig *tls.Config
+
+	conn    net.Conn
+	closed  bool
+	retry   time.Time // the time that can reconnect
+	backoff time.Duration
+	mutex   sync.Mutex
+}
+
+// newSyslogOutput is used to create syslog output, the address can be
+// "local", "unix:///dev/log", "unixgram:///dev/log", "udp://host:514",
+// "tcp://host:514" and "tls://host:6514", it connects the server when
+// the first log is sent, so the unavailable server will not block start.
+func newSyslogOutput(address, tag string) (*syslogOutput, error) {
+	output := syslogOutput{
+		tag: tag,
+		pid: os.Getpid(),
+	}
+	if address == "local" {
+		output.local = true
+	} else {
+		URL, err := url.Parse(address)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid syslog address")
+		}
+		switch URL.Scheme {
+		case "unix", "unixgram":
+			output.network = URL.Scheme
+			output.address = URL.Path
+			output.local = true
+		case "udp", "tcp":
+			output.network = URL.Scheme
+			output.address = URL.Host
+		case "tls":
+			output.network = "tcp"
+			output.address = URL.Host
+			output.tlsConfig = &tls.Config{
+				ServerName: URL.Hostname(),
+				MinVersion: tls.VersionTLS12,
+			}
+		default:
+			return nil, errors.Errorf("unsupported syslog network: \"%s\"", URL.Scheme)
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "-"
+	}
+	output.host = host
+	return &output, nil
+}
+
+func (s *syslogOutput) connect() error {
+	if s.local && s.address == "" {
+		for _, path := range localSyslogSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err := net.Dial(network, path)
+				if err == nil {
+					s.conn = conn
+					return nil
+				}
+			}
+		}
+		return errors.New("failed to connect local syslog daemon")
+	}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tlsConfig != nil {
+		dialer := net.Dialer{Timeout: syslogTimeout}
+		conn, err = tls.DialWithDialer(&dialer, s.network, s.address, s.tlsConfig)
+	} else {
+		conn, err = net.DialTimeout(s.network, s.address, syslogTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Log will send the log line with the write deadline, if failed to send,
+// it will reconnect and retry once, the reconnection is delayed with the
+// backoff after failed, and the log is dropped during the backoff.
+func (s *syslogOutput) Log(lv logLevel, msg string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	now := time.Now()
+	data := s.format(lv, msg, now)
+	if s.conn != nil {
+		err := s.write(data)
+		if err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	if now.Before(s.retry) {
+		return errors.Errorf("syslog is unavailable, reconnect after %s", s.retry.Sub(now).Round(time.Millisecond))
+	}
+	// reconnect and retry once
+	err := s.connect()
+	if err != nil {
+		s.delay(now)
+		return err
+	}
+	err = s.write(data)
+	if err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		s.delay(now)
+		return err
+	}
+	s.backoff = 0
+	return nil
+}
+
+func (s *syslogOutput) write(data []byte) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// delay is used to double the backoff about reconnect.
+func (s *syslogOutput) delay(now time.Time) {
+	s.backoff *= 2
+	if s.backoff < syslogMinBackoff {
+		s.backoff = syslogMinBackoff
+	}
+	if s.backoff > syslogMaxBackoff {
+		s.backoff = syslogMaxBackoff
+	}
+	s.retry = now.Add(s.backoff)
+}
+
+func (s *syslogOutput) format(lv logLevel, msg string, now time.Time) []byte {
+	pri := syslogFacility*8 + lv.priority()
+	if s.local {
+		ts := now.Format(time.Stamp)
+		return []byte(fmt.Sprintf("<%d>%s %s[%d]: %s\n", pri, ts, s.tag, s.pid, msg))
+	}
+	ts := now.Format("2006-01-02T15:04:05.000000Z07:00")
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", pri, ts, s.host, s.tag, s.pid, msg)
+	if s.network == "udp" {
+		return []byte(line)
+	}
+	// use octet counting for stream transport
+	return []byte(strconv.Itoa(len(line)) + " " + line)
+}
+
+// Close will close the connection, the logs after close are discarded.
+func (s *syslogOutput) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,265 @@
+package ddns
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogOutput(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		output, err := newSyslogOutput("udp://"+conn.LocalAddr().String(), "test")
+		require.NoError(t, err)
+
+		err = output.Log(levelError, "message")
+		require.NoError(t, err)
+
+		buf := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		line := string(buf[:n])
+		require.True(t, strings.HasPrefix(line, "<27>1 "), line)
+		require.True(t, strings.HasSuffix(line, " test "+strconv.Itoa(output.pid)+" - - message"), line)
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = listener.Close() }()
+
+		output, err := newSyslogOutput("tcp://"+listener.Addr().String(), "test")
+		require.NoError(t, err)
+
+		// the connection is created when the first log is sent
+		err = output.Log(levelInfo, "message")
+		require.NoError(t, err)
+		conn, err := listener.Accept()
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		testReadOctetCounting(t, bufio.NewReader(conn), "<30>1 ")
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		cert, pool := testGenerateCert(t)
+		tlsCfg := tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tlsCfg)
+		require.NoError(t, err)
+		defer func() { _ = listener.Close() }()
+
+		output := syslogOutput{
+			network: "tcp",
+			address: listener.Addr().String(),
+			tag:     "test",
+			host:    "host",
+			tlsConfig: &tls.Config{
+				RootCAs:    pool,
+				ServerName: "127.0.0.1",
+				MinVersion: tls.VersionTLS12,
+			},
+		}
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			accepted <- conn
+		}()
+
+		err = output.Log(levelWarning, "message")
+		require.NoError(t, err)
+
+		conn := <-accepted
+		require.NotNil(t, conn)
+		defer func() { _ = conn.Close() }()
+		testReadOctetCounting(t, bufio.NewReader(conn), "<28>1 ")
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("local", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("unixgram is not supported on windows")
+		}
+		path := filepath.Join(t.TempDir(), "log.sock")
+		addr := net.UnixAddr{Name: path, Net: "unixgram"}
+		server, err := net.ListenUnixgram("unixgram", &addr)
+		require.NoError(t, err)
+		defer func() { _ = server.Close() }()
+
+		output, err := newSyslogOutput("unixgram://"+path, "test")
+		require.NoError(t, err)
+
+		err = output.Log(levelFatal, "message")
+		require.NoError(t, err)
+
+		buf := make([]byte, 1024)
+		n, err := server.Read(buf)
+		require.NoError(t, err)
+		line := string(buf[:n])
+		require.True(t, strings.HasPrefix(line, "<26>"), line)
+		require.True(t, strings.HasSuffix(line, "test["+strconv.Itoa(output.pid)+"]: message\n"), line)
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		output, err := newSyslogOutput("tcp://"+listener.Addr().String(), "test")
+		require.NoError(t, err)
+		err = output.Log(levelInfo, "message")
+		require.NoError(t, err)
+		conn, err := listener.Accept()
+		require.NoError(t, err)
+
+		// the server is down
+		_ = listener.Close()
+		_ = conn.Close()
+		require.Eventually(t, func() bool {
+			return output.Log(levelInfo, "message") != nil
+		}, 3*time.Second, 10*time.Millisecond)
+
+		// the reconnection is delayed
+		start := time.Now()
+		err = output.Log(levelInfo, "message")
+		require.ErrorContains(t, err, "syslog is unavailable, reconnect after")
+		require.Less(t, time.Since(start), time.Second)
+		require.Nil(t, output.conn)
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("connect lazily", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		err = listener.Close()
+		require.NoError(t, err)
+
+		// the server is unavailable when create
+		output, err := newSyslogOutput("tcp://"+address, "test")
+		require.NoError(t, err)
+		err = output.Log(levelInfo, "message")
+		require.Error(t, err)
+		require.Nil(t, output.conn)
+
+		err = output.Close()
+		require.NoError(t, err)
+	})
+
+	t.Run("log after close", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		output, err := newSyslogOutput("udp://"+conn.LocalAddr().String(), "test")
+		require.NoError(t, err)
+		err = output.Close()
+		require.NoError(t, err)
+
+		err = output.Log(levelInfo, "message")
+		require.NoError(t, err)
+		require.Nil(t, output.conn)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		output, err := newSyslogOutput("foo://127.0.0.1:514", "test")
+		require.EqualError(t, err, "unsupported syslog network: \"foo\"")
+		require.Nil(t, output)
+	})
+}
+
+func TestLogger_Syslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	cfg := Config{LogSyslog: "udp://" + conn.LocalAddr().String()}
+	logger, err := newLogger(&cfg)
+	require.NoError(t, err)
+
+	logger.Warningf("warningf: %s", "warning")
+
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	line := string(buf[:n])
+	require.True(t, strings.HasPrefix(line, "<28>1 "), line)
+	require.Contains(t, line, " ddns-updater ")
+	require.True(t, strings.HasSuffix(line, "warningf: warning"), line)
+
+	err = logger.Close()
+	require.NoError(t, err)
+}
+
+func testReadOctetCounting(t *testing.T, r *bufio.Reader, prefix string) {
+	size, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	require.NoError(t, err)
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(buf), prefix), string(buf))
+	require.True(t, strings.HasSuffix(string(buf), "message"), string(buf))
+}
+
+func testGenerateCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(crt)
+	cert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+	return cert, pool
+}
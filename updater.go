@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,14 +26,36 @@ const (
 // them to the DDNS provider.
 type Updater struct {
 	period    time.Duration
-	logger    *logger
+	timeout   time.Duration
+	dryRun    bool
+	clock     Clock
+	logger    Logger
+	closer    io.Closer
+	notifier  *notifier
+	verifier  *verifier
+	checker   *checker
 	providers []*provider
+	events    eventBus
+	flight    *flight
 
-	pubIPv4Req    *http.Request
-	pubIPv6Req    *http.Request
-	pubIPv4Client *http.Client
-	pubIPv6Client *http.Client
-	pushIPClient  *http.Client
+	// trigger is used to request an update from the loop,
+	// reset is used to reset the ticker after manual update.
+	trigger chan struct{}
+	reset   chan struct{}
+
+	// uplinks[0] is the default uplink without name.
+	uplinks      []*uplink
+	pushIPClient *http.Client
+
+	// the clients about the provider that the family is forced
+	pushIPv4Client *http.Client
+	pushIPv6Client *http.Client
+
+	// status about the last update
+	status      status
+	statusMu    sync.Mutex
+	readyOnce   sync.Once
+	updateStart int64
 
 	ctx      context.Context
 	cancel   context.CancelFunc
@@ -42,8 +65,10 @@ type Updater struct {
 	wg       sync.WaitGroup
 }
 
-// NewUpdater is used to create a new ddns updater.
-func NewUpdater(cfg *Config) (*Updater, error) {
+// NewUpdater is used to create a new ddns updater, the options
+// can replace the transport, clock, resolver and logger of it.
+func NewUpdater(cfg *Config, opts ...Option) (*Updater, error) {
+	o := newOptions(opts)
 	period := time.Duration(cfg.Period)
 	if period == 0 {
 		period = defaultUpdatePeriod
@@ -52,156 +77,139 @@ func NewUpdater(cfg *Config) (*Updater, error) {
 	if timeout == 0 {
 		timeout = defaultUpdateTimeout
 	}
-	logger, err := newLogger(cfg.LogFile)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create logger")
+	var closer io.Closer
+	logger := o.logger
+	if logger == nil {
+		l, err := newLogger(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create logger")
+		}
+		logger = l
+		closer = l
 	}
 	var ok bool
 	defer func() {
-		if ok {
+		if ok || closer == nil {
 			return
 		}
-		_ = logger.Close()
+		_ = closer.Close()
 	}()
-	if !cfg.PublicIPv4.Enabled && !cfg.PublicIPv6.Enabled {
+	if !cfg.PublicIPv4.Enabled && !cfg.PublicIPv6.Enabled && len(cfg.Uplink) == 0 {
 		return nil, errors.New("IPv4/IPv6 are all disabled")
 	}
-	var (
-		pubIPv4Req    *http.Request
-		pubIPv6Req    *http.Request
-		pubIPv4Client *http.Client
-		pubIPv6Client *http.Client
-	)
-	if cfg.PublicIPv4.Enabled {
-		pubIPv4Req, pubIPv4Client, err = newIPv4HTTPClient(cfg)
-		if err != nil {
-			return nil, err
-		}
-		pubIPv4Client.Timeout = timeout
-	}
-	if cfg.PublicIPv6.Enabled {
-		pubIPv6Req, pubIPv6Client, err = newIPv6HTTPClient(cfg)
-		if err != nil {
-			return nil, err
-		}
-		pubIPv6Client.Timeout = timeout
+	uplinks, err := newUplinks(cfg, timeout, o)
+	if err != nil {
+		return nil, err
 	}
 	providers, err := loadProviders(cfg)
 	if err != nil {
 		return nil, err
 	}
+	for _, provider := range providers {
+		if provider.uplink != "" && findUplink(uplinks, provider.uplink) == nil {
+			return nil, errors.Errorf("unknown uplink %s of provider instance %s", provider.uplink, provider.Name)
+		}
+	}
 	proxy, err := readProxyURL(cfg.Provider.ProxyURL)
 	if err != nil {
 		return nil, err
 	}
-	tr := &http.Transport{
-		Proxy: proxy,
+	iface := cfg.Provider.Interface
+	pushIPClient := newPushIPClient(proxy, iface, "", timeout, o)
+	pushIPv4Client := newPushIPClient(proxy, iface, "tcp4", timeout, o)
+	pushIPv6Client := newPushIPClient(proxy, iface, "tcp6", timeout, o)
+	notifier, err := newNotifier()
+	if err != nil {
+		return nil, err
 	}
-	pushIPClient := &http.Client{
-		Transport: tr,
-		Timeout:   timeout,
+	var verifier *verifier
+	if cfg.Verify.Enabled {
+		verifier = newVerifier(cfg, o.clock, o.resolver)
+	}
+	var checker *checker
+	if cfg.Check.Enabled {
+		checker = newChecker(cfg, timeout, o.resolver)
 	}
 	updater := Updater{
-		period:        period,
-		logger:        logger,
-		providers:     providers,
-		pubIPv4Req:    pubIPv4Req,
-		pubIPv6Req:    pubIPv6Req,
-		pubIPv4Client: pubIPv4Client,
-		pubIPv6Client: pubIPv6Client,
-		pushIPClient:  pushIPClient,
+		period:         period,
+		timeout:        timeout,
+		dryRun:         cfg.DryRun,
+		clock:          o.clock,
+		logger:         logger,
+		closer:         closer,
+		notifier:       notifier,
+		verifier:       verifier,
+		checker:        checker,
+		providers:      providers,
+		uplinks:        uplinks,
+		pushIPClient:   pushIPClient,
+		pushIPv4Client: pushIPv4Client,
+		pushIPv6Client: pushIPv6Client,
+		trigger:        make(chan struct{}, 1),
+		reset:          make(chan struct{}, 1),
 	}
 	updater.ctx, updater.cancel = context.WithCancel(context.Background())
+	updater.flight = newFlight(updater.ctx, &updater.wg)
 	ok = true
 	return &updater, nil
 }
 
-func newIPv4HTTPClient(cfg *Config) (*http.Request, *http.Client, error) {
-	req, err := http.NewRequest(http.MethodGet, cfg.PublicIPv4.URL, nil)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "invalid url about public ipv4 address provider")
-	}
-	proxy, err := readProxyURL(cfg.PublicIPv4.ProxyURL)
-	if err != nil {
-		return nil, nil, err
+// addrPolicy is the policy about the detected address of a family.
+type addrPolicy struct {
+	allowBogon bool
+	ipv6Only   bool
+}
+
+func newAddrPolicy(cfg *PublicIP) addrPolicy {
+	return addrPolicy{
+		allowBogon: cfg.AllowBogon,
+		ipv6Only:   cfg.CGNAT == cgnatIPv6Only,
 	}
-	tr := &http.Transport{
-		Proxy: proxy,
+}
+
+// newPushIPClient is used to create the client about the requests to the
+// DDNS providers, if the network is not empty, the connections are dialed
+// with it, the family is not forced when connect the proxy.
+func newPushIPClient(proxy func(*http.Request) (*url.URL, error), iface, network string, timeout time.Duration, o *options) *http.Client {
+	var tr http.RoundTripper = o.providerTransport
+	if tr == nil {
+		tr = &http.Transport{
+			Proxy:       proxy,
+			DialContext: dialProvider(iface, network, proxy != nil),
+		}
 	}
-	client := &http.Client{
+	return &http.Client{
 		Transport: tr,
+		Timeout:   timeout,
 	}
-	la := cfg.PublicIPv4.LocalAddr
-	if la == "" {
-		return req, client, nil
-	}
-	if net.ParseIP(la) != nil {
-		la = net.JoinHostPort(la, "0")
-	}
-	lAddr, err := net.ResolveTCPAddr("tcp4", la)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "invalid local ipv4 address")
-	}
-	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		dialer := net.Dialer{
-			LocalAddr: lAddr,
-		}
-		if proxy == nil {
-			return dialer.DialContext(ctx, network, addr)
-		}
-		conn, err := dialer.DialContext(ctx, network, addr)
-		if err == nil {
-			return conn, nil
+}
+
+// dialProvider returns the dial function about the requests to the DDNS
+// providers, the network is replaced with the forced network if it is not
+// empty, and the connections are bound to the interface if it is set.
+func dialProvider(iface, forced string, proxied bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dial := new(net.Dialer).DialContext
+	if iface != "" {
+		dial = dialInterface(iface)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if forced != "" && !proxied {
+			network = forced
 		}
-		dialer.LocalAddr = nil
-		return dialer.DialContext(ctx, network, addr)
+		return dial(ctx, network, addr)
 	}
-	tr.DialContext = dialContext
-	return req, client, nil
 }
 
-func newIPv6HTTPClient(cfg *Config) (*http.Request, *http.Client, error) {
-	req, err := http.NewRequest(http.MethodGet, cfg.PublicIPv6.URL, nil)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "invalid url about public ipv6 address provider")
-	}
-	proxy, err := readProxyURL(cfg.PublicIPv6.ProxyURL)
-	if err != nil {
-		return nil, nil, err
-	}
-	tr := &http.Transport{
-		Proxy: proxy,
-	}
-	client := &http.Client{
-		Transport: tr,
-	}
-	la := cfg.PublicIPv6.LocalAddr
-	if la == "" {
-		return req, client, nil
+// pushClient returns the client about the push request of the family.
+func (updater *Updater) pushClient(provider *provider, ipv4 bool) *http.Client {
+	switch {
+	case !provider.cfg.Meta.ForceFamily:
+		return updater.pushIPClient
+	case ipv4:
+		return updater.pushIPv4Client
+	default:
+		return updater.pushIPv6Client
 	}
-	if net.ParseIP(la) != nil {
-		la = net.JoinHostPort(la, "0")
-	}
-	lAddr, err := net.ResolveTCPAddr("tcp6", la)
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "invalid local ipv6 address")
-	}
-	dialContext := func(ctx context.Context, network, addr string) (net.Conn, error) {
-		dialer := net.Dialer{
-			LocalAddr: lAddr,
-		}
-		if proxy == nil {
-			return dialer.DialContext(ctx, network, addr)
-		}
-		conn, err := dialer.DialContext(ctx, network, addr)
-		if err == nil {
-			return conn, nil
-		}
-		dialer.LocalAddr = nil
-		return dialer.DialContext(ctx, network, addr)
-	}
-	tr.DialContext = dialContext
-	return req, client, nil
 }
 
 func readProxyURL(URL string) (func(*http.Request) (*url.URL, error), error) {
@@ -219,29 +227,55 @@ func readProxyURL(URL string) (func(*http.Request) (*url.URL, error), error) {
 }
 
 func loadProviders(cfg *Config) ([]*provider, error) {
-	l := len(cfg.Provider.Item)
-	if l < 0 {
+	items, err := cfg.providerItems()
+	if err != nil {
+		return nil, err
+	}
+	l := len(items) + len(cfg.Provider.Instance)
+	if l == 0 {
 		return nil, errors.New("empty provider")
 	}
 	providers := make([]*provider, 0, l)
-	for i := 0; i < l; i++ {
-		path := filepath.Join(cfg.Provider.Dir, cfg.Provider.Item[i])
-		provider, err := loadProvider(path)
+	for i := 0; i < len(items); i++ {
+		path := filepath.Join(cfg.Provider.Dir, items[i])
+		provider, err := loadProvider(path, nil)
 		if err != nil {
 			return nil, err
 		}
+		provider.Name = items[i]
+		providers = append(providers, provider)
+	}
+	names := make(map[string]bool, len(cfg.Provider.Instance))
+	for i := 0; i < len(cfg.Provider.Instance); i++ {
+		instance := cfg.Provider.Instance[i]
+		name := strings.ToLower(instance.Name)
+		if names[name] {
+			return nil, errors.Errorf("provider instance %s is already exists", instance.Name)
+		}
+		names[name] = true
+		path := filepath.Join(cfg.Provider.Dir, instance.Template)
+		provider, err := loadProvider(path, instance.Args)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to load provider instance %s", instance.Name)
+		}
+		provider.Name = instance.Name
+		provider.uplink = instance.Uplink
+		provider.ipv6ID, err = parseInterfaceID(instance.IPv6Suffix, instance.IPv6MAC)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid provider instance %s", instance.Name)
+		}
 		providers = append(providers, provider)
 	}
 	return providers, nil
 }
 
-func loadProvider(path string) (*provider, error) {
+func loadProvider(path string, args map[string]string) (*provider, error) {
 	file, err := os.Open(path + ".toml")
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to read provider config file")
 	}
 	defer func() { _ = file.Close() }()
-	provider, err := newProvider(file)
+	provider, err := newProvider(file, args)
 	if err != nil {
 		return nil, err
 	}
@@ -254,118 +288,416 @@ func (updater *Updater) Run() {
 	updater.runOnce.Do(func() {
 		updater.wg.Add(1)
 		go updater.run()
+		if updater.notifier != nil && updater.notifier.watchdog > 0 {
+			updater.wg.Add(1)
+			go updater.watchdog()
+		}
 		updater.logger.Info("ddns-updater is running")
 	})
 }
 
 func (updater *Updater) run() {
 	defer updater.wg.Done()
-	ticker := time.NewTicker(updater.period)
+	ticker := updater.clock.NewTicker(updater.period)
 	defer ticker.Stop()
+	reset := func() {
+		ticker.Reset(updater.period)
+		// discard the tick that fired before reset
+		select {
+		case <-ticker.C():
+		default:
+		}
+	}
 	for {
 		select {
-		case <-ticker.C:
-			updater.Update()
+		case <-ticker.C():
+			// a manual update is just finished, skip this tick
+			select {
+			case <-updater.reset:
+				reset()
+				continue
+			default:
+			}
+			// skip this tick if a manual update is running
+			updater.flight.TryDo(updater.ctx, updater.updateContext)
+		case <-updater.trigger:
+			_, _ = updater.UpdateContext(updater.ctx)
+		case <-updater.reset:
+			reset()
 		case <-updater.ctx.Done():
 			return
 		}
 	}
 }
 
-func (updater *Updater) Update() {
-	ipv4, err := updater.getPublicIPv4()
-	if err != nil {
-		updater.logger.Error("failed to get public ipv4 address:", err)
-		return
-	}
-	ipv6, err := updater.getPublicIPv6()
-	if err != nil {
-		updater.logger.Error("failed to get public ipv6 address:", err)
-		return
+// Trigger is used to request an update without wait, the update will run
+// in the loop started by Run, the triggers that arrive during an update
+// are merged into one follow-up update.
+func (updater *Updater) Trigger() {
+	select {
+	case updater.trigger <- struct{}{}:
+	default:
 	}
+}
+
+// Update is used to update the records once, the result is only
+// logged, use UpdateContext to get the report about the update.
+func (updater *Updater) Update() {
+	_, _ = updater.UpdateContext(context.Background())
+}
+
+// UpdateContext is used to update the records once with the context, it
+// returns the report about the update, if failed to detect the addresses
+// or any record is failed to update, it returns the report with error.
+// The updates are serialized, the calls that arrive during an update are
+// merged into one follow-up update, and the ticker of the loop started
+// by Run will be reset after it. The follow-up update is not canceled
+// when the merged calls stop waiting, it is only canceled by Stop, and
+// Stop will wait it finished, the calls after Stop return error.
+func (updater *Updater) UpdateContext(ctx context.Context) (*Report, error) {
+	report, err := updater.flight.Do(ctx, updater.updateContext)
+	select {
+	case updater.reset <- struct{}{}:
+	default:
+	}
+	return report, err
+}
+
+func (updater *Updater) updateContext(ctx context.Context) (*Report, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// the update is also canceled when the updater is stopped
+	stop := context.AfterFunc(updater.ctx, cancel)
+	defer stop()
+	start := updater.clock.Now()
+	atomic.StoreInt64(&updater.updateStart, start.UnixNano())
+	defer atomic.StoreInt64(&updater.updateStart, 0)
+	report := Report{
+		Start:   start,
+		Records: []*RecordReport{},
+	}
+	err := updater.update(ctx, &report)
+	report.Duration = Duration(updater.clock.Now().Sub(start))
+	updater.notifyStatus(err == nil)
+	return &report, err
+}
+
+func (updater *Updater) update(ctx context.Context, report *Report) error {
+	// the records of other uplinks are still updated if failed to
+	// detect the addresses of the default uplink
+	def := updater.uplinks[0]
+	var detectErr, err error
+	report.IPv4, report.IPv6, detectErr = updater.detectUplink(ctx, def)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	addrs := make(map[string]*uplinkAddrs, len(updater.uplinks))
+	addrs[""] = updater.saveUplink(def, report.IPv4, report.IPv6, detectErr)
+	for _, u := range updater.uplinks[1:] {
+		r := UplinkReport{Name: u.name}
+		r.IPv4, r.IPv6, err = updater.detectUplink(ctx, u)
+		report.Uplinks = append(report.Uplinks, &r)
+		addrs[u.name] = updater.saveUplink(u, r.IPv4, r.IPv6, err)
+	}
+	records := make([][]*RecordReport, len(updater.providers))
 	wg := sync.WaitGroup{}
 	for i := 0; i < len(updater.providers); i++ {
 		wg.Add(1)
-		go func(p *provider) {
+		go func(i int) {
 			defer wg.Done()
-			updater.pushIP(p, ipv4, ipv6)
-		}(updater.providers[i])
+			provider := updater.providers[i]
+			records[i] = updater.pushIP(ctx, provider, addrs[provider.uplink])
+		}(i)
 	}
 	wg.Wait()
+	for i := 0; i < len(records); i++ {
+		report.Records = append(report.Records, records[i]...)
+	}
+	failed := report.failed()
+	switch {
+	case detectErr != nil && failed != 0:
+		return errors.WithMessagef(detectErr, "%d of %d records failed to update", failed, len(report.Records))
+	case detectErr != nil:
+		return detectErr
+	case failed != 0:
+		return errors.Errorf("%d of %d records failed to update", failed, len(report.Records))
+	}
+	return nil
 }
 
-func (updater *Updater) getPublicIPv4() (string, error) {
-	if updater.pubIPv4Req == nil {
-		return "", nil
+// saveUplink is used to save the detected addresses of the uplink and
+// publish the events about them, the status is about the default uplink.
+func (updater *Updater) saveUplink(u *uplink, ipv4, ipv6 *Detection, err error) *uplinkAddrs {
+	addrs := uplinkAddrs{
+		uplink: u,
+		ipv4:   ipv4.ip(),
+		ipv6:   ipv6.ip(),
+		err:    err,
 	}
-	req := updater.pubIPv4Req.Clone(updater.ctx)
-	resp, err := updater.pubIPv4Client.Do(req)
 	if err != nil {
-		return "", err
+		return &addrs
+	}
+	updater.statusMu.Lock()
+	prevIPv4 := u.ipv4
+	prevIPv6 := u.ipv6
+	u.ipv4 = addrs.ipv4
+	u.ipv6 = addrs.ipv6
+	if u.name == "" {
+		updater.status.IPv4 = addrs.ipv4
+		updater.status.IPv6 = addrs.ipv6
+		updater.status.CGNAT = ipv4 != nil && ipv4.CGNAT
+		updater.status.IPv4Skipped = ipv4 != nil && ipv4.Skipped
+	}
+	updater.statusMu.Unlock()
+	updater.publishIP(u.name, "ipv4", addrs.ipv4, prevIPv4)
+	updater.publishIP(u.name, "ipv6", addrs.ipv6, prevIPv6)
+	if ipv4 != nil && ipv4.Skipped {
+		addrs.ipv4 = ""
+	}
+	return &addrs
+}
+
+// detect is used to get the public address from the sources in order
+// and return the detection about it, the first valid address is used,
+// the bogon address is refused unless it is allowed by policy, if the
+// family is disabled, it will return nil.
+func (updater *Updater) detect(ctx context.Context, sources []source, family string, policy addrPolicy) (*Detection, error) {
+	if len(sources) == 0 {
+		return nil, nil
 	}
-	defer func() {
-		_, _ = io.Copy(io.Discard, resp.Body)
-		_ = resp.Body.Close()
-	}()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	ipv4 := family == "ipv4"
+	start := updater.clock.Now()
+	d := Detection{}
+	var (
+		names []string
+		errs  []string
+		err   error
+	)
+	// the addresses of local sources are reused when check CGNAT
+	locals := make(map[source]net.IP)
+	for _, src := range sources {
+		var ip net.IP
+		ip, err = src.Detect(ctx)
+		if err == nil {
+			err = checkIPFamily(ip, ipv4)
+		}
+		if err == nil && isLocalSource(src) {
+			locals[src] = ip
+		}
+		if err == nil && !policy.allowBogon && isBogon(ip) {
+			err = errors.Errorf("%s is a bogon address", ip)
+		}
+		if err == nil {
+			d.Source = src.String()
+			d.IP = ip.String()
+			d.Duration = Duration(updater.clock.Now().Sub(start))
+			updater.logger.Info(family+":", d.IP)
+			if ipv4 {
+				updater.checkCGNAT(ctx, &d, ip, sources, locals)
+			}
+			return &d, nil
+		}
+		names = append(names, src.String())
+		errs = append(errs, src.String()+": "+err.Error())
+		if len(sources) > 1 {
+			updater.logger.Warningf("failed to get public %s address from %s: %s", family, src, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	d.Source = strings.Join(names, ", ")
+	d.Duration = Duration(updater.clock.Now().Sub(start))
+	// keep the original error if only one source is used
+	if len(sources) == 1 {
+		d.Error = err.Error()
+		return &d, err
 	}
-	ip := string(data)
-	updater.logger.Info("ipv4:", ip)
-	return ip, nil
+	d.Error = strings.Join(errs, "; ")
+	if ctx.Err() != nil {
+		return &d, ctx.Err()
+	}
+	return &d, errors.New(d.Error)
 }
 
-func (updater *Updater) getPublicIPv6() (string, error) {
-	if updater.pubIPv6Req == nil {
-		return "", nil
+// checkCGNAT is used to check the public address is behind carrier-grade
+// NAT, the local sources are queried whatever the order of them, if the
+// external address of gateway is different from the public address or
+// the address of interface is in the shared address space, the public
+// address is behind CGNAT. The private address of interface is usual on
+// the host in LAN, so it is not compared with the public address.
+func (updater *Updater) checkCGNAT(ctx context.Context, d *Detection, ip net.IP, sources []source, locals map[source]net.IP) {
+	for _, src := range sources {
+		if !isLocalSource(src) {
+			continue
+		}
+		local, ok := locals[src]
+		if !ok {
+			var err error
+			local, err = src.Detect(ctx)
+			if err != nil || local.To4() == nil {
+				continue
+			}
+		}
+		if !local.Equal(ip) && isBehindCGNAT(src, local, ip) {
+			d.LocalIP = local.String()
+			break
+		}
 	}
-	req := updater.pubIPv6Req.Clone(updater.ctx)
-	resp, err := updater.pubIPv6Client.Do(req)
-	if err != nil {
-		return "", err
+	if d.LocalIP == "" && !sharedNetwork.Contains(ip) {
+		return
 	}
-	defer func() {
-		_, _ = io.Copy(io.Discard, resp.Body)
-		_ = resp.Body.Close()
-	}()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	d.CGNAT = true
+	if d.LocalIP != "" {
+		updater.logger.Warningf("ipv4 address is behind CGNAT, the local address is %s", d.LocalIP)
+		return
 	}
-	ip := string(data)
-	updater.logger.Info("ipv6:", ip)
-	return ip, nil
+	updater.logger.Warning("ipv4 address is behind CGNAT, it is in the shared address space")
 }
 
-func (updater *Updater) pushIP(provider *provider, ipv4, ipv6 string) {
-	if ipv4 != "" {
-		err := updater.pushIPv4(provider, ipv4)
-		if err != nil {
-			updater.logger.Error("failed to push ipv4 address:", err)
-		} else {
-			updater.logger.Info("update ipv4 address successfully")
+// publishIP is used to publish the detected address and publish
+// IPChanged if it is different from the last detected address.
+func (updater *Updater) publishIP(uplink, family, ip, prev string) {
+	if ip == "" {
+		return
+	}
+	event := Event{Type: EventIPDetected, Family: family, IP: ip, Uplink: uplink}
+	updater.publish(event)
+	if ip != prev {
+		event.Type = EventIPChanged
+		event.PrevIP = prev
+		updater.publish(event)
+	}
+}
+
+func (updater *Updater) pushIP(ctx context.Context, provider *provider, addrs *uplinkAddrs) []*RecordReport {
+	if addrs.err != nil {
+		return failedRecords(provider, addrs)
+	}
+	// the verification about the records of provider is limited in the
+	// period, so the slow domains will not delay the next update
+	verifyCtx, cancel := context.WithTimeout(ctx, updater.period)
+	defer cancel()
+	var records []*RecordReport
+	ipv4 := addrs.ipv4
+	ipv6 := addrs.ipv6
+	if ipv4 != "" && provider.Supports(true) {
+		records = append(records, updater.pushRecord(ctx, verifyCtx, provider, dnsTypeA, ipv4))
+	}
+	if ipv6 != "" && provider.Supports(false) {
+		if provider.ipv6ID != nil {
+			ip := combineIPv6(net.ParseIP(ipv6), addrs.uplink.ipv6Prefix, provider.ipv6ID)
+			ipv6 = ip.String()
 		}
+		records = append(records, updater.pushRecord(ctx, verifyCtx, provider, dnsTypeAAAA, ipv6))
 	}
-	if ipv6 != "" {
-		err := updater.pushIPv6(provider, ipv6)
-		if err != nil {
-			updater.logger.Error("failed to push ipv6 address:", err)
-		} else {
-			updater.logger.Info("update ipv6 address successfully")
+	return records
+}
+
+// failedRecords returns the failed records of the provider when failed
+// to detect the addresses of the uplink that referenced by it.
+func failedRecords(provider *provider, addrs *uplinkAddrs) []*RecordReport {
+	var records []*RecordReport
+	for _, family := range []string{"ipv4", "ipv6"} {
+		ipv4 := family == "ipv4"
+		sources := addrs.uplink.ipv6Sources
+		if ipv4 {
+			sources = addrs.uplink.ipv4Sources
+		}
+		if len(sources) == 0 || !provider.Supports(ipv4) {
+			continue
+		}
+		record := RecordReport{
+			Provider: provider.Name,
+			Uplink:   provider.uplink,
+			Family:   family,
+			Domains:  provider.Domains,
+			Result:   ResultFailed,
+			Error:    addrs.err.Error(),
+		}
+		records = append(records, &record)
+	}
+	return records
+}
+
+// pushRecord is used to push the address to the provider, it will
+// check the current record before push and verify it after push.
+func (updater *Updater) pushRecord(ctx, verifyCtx context.Context, provider *provider, typ uint16, ip string) *RecordReport {
+	family := "ipv4"
+	push := updater.pushIPv4
+	if typ == dnsTypeAAAA {
+		family = "ipv6"
+		push = updater.pushIPv6
+	}
+	record := RecordReport{
+		Provider: provider.Name,
+		Uplink:   provider.uplink,
+		Family:   family,
+		Domains:  provider.Domains,
+		IP:       ip,
+	}
+	start := updater.clock.Now()
+	defer func() {
+		record.Duration = Duration(updater.clock.Now().Sub(start))
+	}()
+	if updater.upToDate(ctx, provider, typ, ip) {
+		updater.logger.Infof("%s address is up to date, skip push", family)
+		record.Result = ResultUpToDate
+		return &record
+	}
+	// the request is only printed in dry run mode, so the events
+	// about push are not published, since nothing is sent
+	event := Event{
+		Type:     EventPushStarted,
+		Family:   family,
+		IP:       ip,
+		Uplink:   provider.uplink,
+		Provider: provider.Name,
+	}
+	if !updater.dryRun {
+		updater.publish(event)
+	}
+	err := push(ctx, provider, ip)
+	if err != nil {
+		updater.logger.Errorf("failed to push %s address: %s", family, err)
+		if !updater.dryRun {
+			event.Type = EventPushFailed
+			event.Err = err
+			updater.publish(event)
 		}
+		record.Result = ResultFailed
+		record.Error = err.Error()
+		return &record
+	}
+	if updater.dryRun {
+		updater.logger.Infof("dry run, %s address is not pushed", family)
+		record.Result = ResultDryRun
+		return &record
+	}
+	updater.logger.Infof("update %s address successfully", family)
+	event.Type = EventPushSucceeded
+	updater.publish(event)
+	record.Result = ResultUpdated
+	d, err := updater.verifyIP(verifyCtx, provider, typ, ip)
+	if err != nil {
+		record.VerifyError = err.Error()
 	}
+	record.Propagation = Duration(d)
+	return &record
 }
 
-func (updater *Updater) pushIPv4(provider *provider, ipv4 string) error {
-	req, err := provider.NewIPv4Request(updater.ctx, ipv4)
+func (updater *Updater) pushIPv4(ctx context.Context, provider *provider, ipv4 string) error {
+	if updater.dryRun {
+		return updater.printRequest(ctx, provider, true, ipv4)
+	}
+	req, err := provider.NewIPv4Request(ctx, ipv4)
 	if err != nil {
 		return err
 	}
 	if req == nil {
 		return nil
 	}
-	resp, err := updater.pushIPClient.Do(req)
+	resp, err := updater.pushClient(provider, true).Do(req)
 	if err != nil {
 		return err
 	}
@@ -386,15 +718,18 @@ func (updater *Updater) pushIPv4(provider *provider, ipv4 string) error {
 	return errors.Errorf("unexcepted response: %s", r)
 }
 
-func (updater *Updater) pushIPv6(provider *provider, ipv6 string) error {
-	req, err := provider.NewIPv6Request(updater.ctx, ipv6)
+func (updater *Updater) pushIPv6(ctx context.Context, provider *provider, ipv6 string) error {
+	if updater.dryRun {
+		return updater.printRequest(ctx, provider, false, ipv6)
+	}
+	req, err := provider.NewIPv6Request(ctx, ipv6)
 	if err != nil {
 		return err
 	}
 	if req == nil {
 		return nil
 	}
-	resp, err := updater.pushIPClient.Do(req)
+	resp, err := updater.pushClient(provider, false).Do(req)
 	if err != nil {
 		return err
 	}
@@ -415,13 +750,44 @@ func (updater *Updater) pushIPv6(provider *provider, ipv6 string) error {
 	return errors.Errorf("unexcepted response: %s", r)
 }
 
+// printRequest is used to print the request instead of send it in dry run mode.
+func (updater *Updater) printRequest(ctx context.Context, provider *provider, ipv4 bool, ip string) error {
+	provider, err := provider.redacted()
+	if err != nil {
+		return err
+	}
+	var req *http.Request
+	if ipv4 {
+		req, err = provider.NewIPv4Request(ctx, ip)
+	} else {
+		req, err = provider.NewIPv6Request(ctx, ip)
+	}
+	if err != nil || req == nil {
+		return err
+	}
+	r, err := renderRequest(req)
+	if err != nil {
+		return err
+	}
+	updater.logger.Info("dry run, skip request:\n" + strings.TrimSuffix(r, "\n"))
+	return nil
+}
+
 func (updater *Updater) Stop() {
 	updater.mutex.Lock()
 	defer updater.mutex.Unlock()
 	updater.stopOnce.Do(func() {
+		updater.notify("STOPPING=1")
+		updater.flight.Close()
 		updater.cancel()
 		updater.wg.Wait()
+		updater.events.Close()
+		if updater.notifier != nil {
+			_ = updater.notifier.Close()
+		}
 		updater.logger.Info("ddns-updater is closed")
-		_ = updater.logger.Close()
+		if updater.closer != nil {
+			_ = updater.closer.Close()
+		}
 	})
 }
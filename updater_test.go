@@ -1 +1,406 @@
 package ddns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// testProviderConfig is used to create config with a provider for test server,
+// the extra is appended to the provider configuration file.
+func testProviderConfig(t *testing.T, host string, extra ...string) *Config {
+	const format = `
+[meta]
+  host_url = "{{.host}}"
+  method   = "GET"
+  response = "good|nochg"
+  domain   = "{{.domain}}"
+
+[ipv4]
+  path = "/update?ip={{.ipv4}}"
+
+[ipv6]
+  path = "/update?ip={{.ipv6}}"
+
+[args]
+  host   = "%s"
+  domain = "test.example.com"
+`
+	dir := t.TempDir()
+	data := fmt.Sprintf(format, host) + strings.Join(extra, "\n")
+	err := os.WriteFile(filepath.Join(dir, "test.toml"), []byte(data), 0600)
+	require.NoError(t, err)
+
+	cfg := Config{}
+	cfg.Provider.Dir = dir
+	cfg.Provider.Item = []string{"test"}
+	return &cfg
+}
+
+// testRoundTripper is a fake transport that handle requests by function.
+type testRoundTripper func(req *http.Request) (string, error)
+
+func (rt testRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	err := req.Context().Err()
+	if err != nil {
+		return nil, err
+	}
+	body, err := rt(req)
+	if err != nil {
+		return nil, err
+	}
+	resp := http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+	return &resp, nil
+}
+
+// testClock is a fake clock that the time and ticks are controlled by test.
+type testClock struct {
+	now    time.Time
+	ticker *testTicker
+	mutex  sync.Mutex
+}
+
+func newTestClock() *testClock {
+	return &testClock{
+		now:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		ticker: &testTicker{c: make(chan time.Time)},
+	}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *testClock) After(d time.Duration) <-chan time.Time {
+	c.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *testClock) NewTicker(time.Duration) Ticker {
+	return c.ticker
+}
+
+// Tick is used to send a tick and wait it is received.
+func (c *testClock) Tick() {
+	c.ticker.c <- c.Now()
+}
+
+type testTicker struct {
+	c      chan time.Time
+	resets int32
+}
+
+func (t *testTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *testTicker) Reset(time.Duration) {
+	atomic.AddInt32(&t.resets, 1)
+}
+
+func (t *testTicker) Stop() {}
+
+// testLogger is a fake logger that records all messages.
+type testLogger struct {
+	logs  []string
+	mutex sync.Mutex
+}
+
+func (l *testLogger) log(lv logLevel, msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.logs = append(l.logs, "["+lv.String()+"] "+strings.TrimSuffix(msg, "\n"))
+}
+
+func (l *testLogger) Info(v ...interface{}) {
+	l.log(levelInfo, fmt.Sprintln(v...))
+}
+
+func (l *testLogger) Infof(format string, v ...interface{}) {
+	l.log(levelInfo, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Warning(v ...interface{}) {
+	l.log(levelWarning, fmt.Sprintln(v...))
+}
+
+func (l *testLogger) Warningf(format string, v ...interface{}) {
+	l.log(levelWarning, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Error(v ...interface{}) {
+	l.log(levelError, fmt.Sprintln(v...))
+}
+
+func (l *testLogger) Errorf(format string, v ...interface{}) {
+	l.log(levelError, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Logs() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string{}, l.logs...)
+}
+
+func TestNewUpdater(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := testProviderConfig(t, "http://127.0.0.1/")
+
+		updater, err := NewUpdater(cfg, WithLogger(new(testLogger)))
+		require.EqualError(t, err, "IPv4/IPv6 are all disabled")
+		require.Nil(t, updater)
+	})
+
+	t.Run("invalid source url", func(t *testing.T) {
+		cfg := testProviderConfig(t, "http://127.0.0.1/")
+		cfg.PublicIPv4.Enabled = true
+		cfg.PublicIPv4.URL = "http://[::1"
+
+		updater, err := NewUpdater(cfg, WithLogger(new(testLogger)))
+		require.Error(t, err)
+		require.Nil(t, updater)
+	})
+
+	t.Run("empty provider", func(t *testing.T) {
+		cfg := new(Config)
+		cfg.PublicIPv4.Enabled = true
+
+		updater, err := NewUpdater(cfg, WithLogger(new(testLogger)))
+		require.EqualError(t, err, "empty provider")
+		require.Nil(t, updater)
+	})
+}
+
+func TestUpdater_Update(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+	cfg.PublicIPv6.Enabled = true
+	cfg.PublicIPv6.URL = "http://ipv6.example.com/"
+
+	source := testRoundTripper(func(req *http.Request) (string, error) {
+		switch req.URL.Host {
+		case "ipv4.example.com":
+			return "1.2.3.4", nil
+		case "ipv6.example.com":
+			return "2400:cb00::1", nil
+		}
+		return "", errors.New("unknown host")
+	})
+	var (
+		pushed []string
+		result = "good"
+		mutex  sync.Mutex
+	)
+	provider := testRoundTripper(func(req *http.Request) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, req.URL.String())
+		return result, nil
+	})
+	clock := newTestClock()
+	logger := new(testLogger)
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithProviderTransport(provider),
+		WithClock(clock), WithLogger(logger),
+	)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	t.Run("success", func(t *testing.T) {
+		updater.Update()
+
+		expected := []string{
+			"http://provider.example.com/update?ip=1.2.3.4",
+			"http://provider.example.com/update?ip=2400:cb00::1",
+		}
+		require.Equal(t, expected, pushed)
+		require.True(t, updater.status.Success)
+		require.Equal(t, "1.2.3.4", updater.status.IPv4)
+		require.Equal(t, "2400:cb00::1", updater.status.IPv6)
+		require.Equal(t, clock.Now(), updater.status.Time)
+		require.Contains(t, logger.Logs(), "[info] update ipv6 address successfully")
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		pushed = nil
+		result = "badauth"
+		defer func() { result = "good" }()
+
+		updater.Update()
+
+		require.Len(t, pushed, 2)
+		require.False(t, updater.status.Success)
+		require.Contains(t, logger.Logs(), "[error] failed to push ipv4 address: unexcepted response: badauth")
+	})
+}
+
+func TestUpdater_Update_IPv6Suffix(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv6.Enabled = true
+	cfg.PublicIPv6.URL = "http://ipv6.example.com/"
+	cfg.PublicIPv6.PrefixLen = 56
+	cfg.Provider.Instance = []ProviderInstance{
+		{
+			Name:       "nas",
+			Template:   "test",
+			Args:       map[string]string{"domain": "nas.example.com"},
+			IPv6Suffix: "::1:0:0:0:1234",
+		},
+		{
+			Name:     "printer",
+			Template: "test",
+			Args:     map[string]string{"domain": "printer.example.com"},
+			IPv6MAC:  "00:11:22:33:44:55",
+		},
+	}
+
+	source := testRoundTripper(func(*http.Request) (string, error) {
+		return "2400:cb00:0:ff00::1", nil
+	})
+	var (
+		pushed []string
+		mutex  sync.Mutex
+	)
+	provider := testRoundTripper(func(req *http.Request) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, req.URL.String())
+		return "good", nil
+	})
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithProviderTransport(provider),
+		WithClock(newTestClock()), WithLogger(new(testLogger)),
+	)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	report, err := updater.UpdateContext(context.Background())
+	require.NoError(t, err)
+	expected := []string{
+		"http://provider.example.com/update?ip=2400:cb00:0:ff00::1",
+		"http://provider.example.com/update?ip=2400:cb00:0:ff01::1234",
+		"http://provider.example.com/update?ip=2400:cb00:0:ff00:211:22ff:fe33:4455",
+	}
+	require.ElementsMatch(t, expected, pushed)
+	require.Equal(t, "2400:cb00:0:ff01::1234", report.Records[1].IP)
+	require.Equal(t, "2400:cb00:0:ff00::1", updater.status.IPv6)
+}
+
+func TestUpdater_Update_ForceFamily(t *testing.T) {
+	var (
+		pushed []string
+		mutex  sync.Mutex
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, r.URL.Query().Get("ip"))
+		_, _ = w.Write([]byte("good"))
+	}))
+	defer server.Close()
+
+	cfg := testProviderConfig(t, server.URL)
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+	cfg.PublicIPv6.Enabled = true
+	cfg.PublicIPv6.URL = "http://ipv6.example.com/"
+	path := filepath.Join(cfg.Provider.Dir, "test.toml")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data = []byte(strings.Replace(string(data), "[meta]\n", "[meta]\n  force_family = true\n", 1))
+	err = os.WriteFile(path, data, 0600)
+	require.NoError(t, err)
+
+	source := testRoundTripper(func(req *http.Request) (string, error) {
+		if req.URL.Host == "ipv4.example.com" {
+			return "1.2.3.4", nil
+		}
+		return "2400:cb00::1", nil
+	})
+	logger := new(testLogger)
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithClock(newTestClock()), WithLogger(logger),
+	)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	// the test server only listens on IPv4
+	updater.Update()
+	require.Equal(t, []string{"1.2.3.4"}, pushed)
+	require.False(t, updater.status.Success)
+	var failed bool
+	for _, log := range logger.Logs() {
+		if strings.HasPrefix(log, "[error] failed to push ipv6 address") {
+			require.Contains(t, log, "dial tcp6")
+			failed = true
+		}
+	}
+	require.True(t, failed)
+}
+
+func TestUpdater_Run(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "http://ipv4.example.com/"
+
+	source := testRoundTripper(func(*http.Request) (string, error) {
+		return "1.2.3.4", nil
+	})
+	pushed := make(chan string, 4)
+	provider := testRoundTripper(func(req *http.Request) (string, error) {
+		pushed <- req.URL.String()
+		return "good", nil
+	})
+	clock := newTestClock()
+	logger := new(testLogger)
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(source), WithProviderTransport(provider),
+		WithClock(clock), WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	updater.Run()
+	for i := 0; i < 3; i++ {
+		clock.Add(time.Minute)
+		clock.Tick()
+		require.Equal(t, "http://provider.example.com/update?ip=1.2.3.4", <-pushed)
+	}
+	updater.Stop()
+
+	require.Empty(t, pushed)
+	logs := logger.Logs()
+	require.Equal(t, "[info] ddns-updater is running", logs[0])
+	require.Equal(t, "[info] ddns-updater is closed", logs[len(logs)-1])
+}
@@ -0,0 +1,142 @@
+package ddns
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// uplink is the public IP address sources about a WAN link, the default
+// uplink without name is configured by public_ipv4 and public_ipv6.
+type uplink struct {
+	name        string
+	ipv4Sources []source
+	ipv6Sources []source
+	ipv4Policy  addrPolicy
+	ipv6Policy  addrPolicy
+	ipv6Prefix  int
+
+	// detectTime is the longest time about detect the addresses.
+	detectTime time.Duration
+
+	// the addresses detected by the last update, they are
+	// protected by the status mutex of updater.
+	ipv4 string
+	ipv6 string
+}
+
+func newUplink(name string, ipv4, ipv6 *PublicIP, timeout time.Duration, o *options) (*uplink, error) {
+	u := uplink{
+		name:       name,
+		ipv4Policy: newAddrPolicy(ipv4),
+		ipv6Policy: newAddrPolicy(ipv6),
+		ipv6Prefix: ipv6.PrefixLen,
+	}
+	if u.ipv6Prefix == 0 {
+		u.ipv6Prefix = defaultIPv6PrefixLen
+	}
+	var err error
+	if ipv4.Enabled {
+		u.ipv4Sources, err = newSources(ipv4, true, timeout, o)
+		if err != nil {
+			return nil, err
+		}
+		u.detectTime += maxDetectTime(ipv4, timeout)
+	}
+	if ipv6.Enabled {
+		u.ipv6Sources, err = newSources(ipv6, false, timeout, o)
+		if err != nil {
+			return nil, err
+		}
+		u.detectTime += maxDetectTime(ipv6, timeout)
+	}
+	return &u, nil
+}
+
+// maxDetectTime returns the longest time about try all the sources in
+// order, the servers of stun source are also tried in order.
+func maxDetectTime(cfg *PublicIP, timeout time.Duration) time.Duration {
+	var d time.Duration
+	for _, src := range cfg.sources() {
+		n := 1
+		if src.Type == sourceSTUN {
+			n = len(src.Servers)
+			if n == 0 {
+				n = len(defaultSTUNServers)
+			}
+		}
+		d += time.Duration(n) * sourceTimeout(&src, timeout)
+	}
+	return d
+}
+
+// newUplinks returns the default uplink and the named uplinks in order.
+func newUplinks(cfg *Config, timeout time.Duration, o *options) ([]*uplink, error) {
+	def, err := newUplink("", &cfg.PublicIPv4, &cfg.PublicIPv6, timeout, o)
+	if err != nil {
+		return nil, err
+	}
+	uplinks := []*uplink{def}
+	for i := 0; i < len(cfg.Uplink); i++ {
+		c := &cfg.Uplink[i]
+		if c.Name == "" {
+			return nil, errors.New("empty uplink name")
+		}
+		if !c.PublicIPv4.Enabled && !c.PublicIPv6.Enabled {
+			return nil, errors.Errorf("IPv4/IPv6 of uplink %s are all disabled", c.Name)
+		}
+		if findUplink(uplinks[1:], c.Name) != nil {
+			return nil, errors.Errorf("uplink %s is already exists", c.Name)
+		}
+		u, err := newUplink(c.Name, &c.PublicIPv4, &c.PublicIPv6, timeout, o)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to create uplink %s", c.Name)
+		}
+		uplinks = append(uplinks, u)
+	}
+	return uplinks, nil
+}
+
+func findUplink(uplinks []*uplink, name string) *uplink {
+	for _, u := range uplinks {
+		if u.name == name {
+			return u
+		}
+	}
+	return nil
+}
+
+// detectUplink is used to detect the addresses of the uplink, if the
+// IPv4 address is behind CGNAT and the IPv6-only fallback is enabled,
+// the IPv4 detection is marked as skipped.
+func (updater *Updater) detectUplink(ctx context.Context, u *uplink) (*Detection, *Detection, error) {
+	suffix := ""
+	if u.name != "" {
+		suffix = " of uplink " + u.name
+	}
+	ipv4, err := updater.detect(ctx, u.ipv4Sources, "ipv4", u.ipv4Policy)
+	if err != nil {
+		updater.logger.Errorf("failed to get public ipv4 address%s: %s", suffix, err)
+		return ipv4, nil, errors.WithMessage(err, "failed to get public ipv4 address"+suffix)
+	}
+	ipv6, err := updater.detect(ctx, u.ipv6Sources, "ipv6", u.ipv6Policy)
+	if err != nil {
+		updater.logger.Errorf("failed to get public ipv6 address%s: %s", suffix, err)
+		return ipv4, ipv6, errors.WithMessage(err, "failed to get public ipv6 address"+suffix)
+	}
+	if ipv4 != nil && ipv4.CGNAT && u.ipv4Policy.ipv6Only && ipv6.ip() != "" {
+		updater.logger.Warningf("ipv4 address%s is behind CGNAT, only update ipv6 records", suffix)
+		ipv4.Skipped = true
+	}
+	return ipv4, ipv6, nil
+}
+
+// uplinkAddrs is the addresses of an uplink that used to push records,
+// the IPv4 address is empty if it is skipped about CGNAT.
+type uplinkAddrs struct {
+	uplink *uplink
+	ipv4   string
+	ipv6   string
+	err    error
+}
@@ -0,0 +1,207 @@
+package ddns
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUplinks(t *testing.T) {
+	o := newOptions(nil)
+	cfg := new(Config)
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = "https://api.ipify.org/"
+	cfg.Uplink = []Uplink{
+		{Name: "wan1"},
+		{Name: "wan2"},
+	}
+	cfg.Uplink[0].PublicIPv4.Enabled = true
+	cfg.Uplink[0].PublicIPv4.URL = "https://api.ipify.org/"
+	cfg.Uplink[0].PublicIPv4.LocalAddr = "192.168.1.2"
+	cfg.Uplink[1].PublicIPv6.Enabled = true
+	cfg.Uplink[1].PublicIPv6.URL = "https://api6.ipify.org/"
+	cfg.Uplink[1].PublicIPv6.PrefixLen = 56
+
+	uplinks, err := newUplinks(cfg, time.Second, o)
+	require.NoError(t, err)
+	require.Len(t, uplinks, 3)
+	require.Empty(t, uplinks[0].name)
+	require.Len(t, uplinks[0].ipv4Sources, 1)
+	require.Equal(t, "wan1", uplinks[1].name)
+	require.Len(t, uplinks[1].ipv4Sources, 1)
+	require.Empty(t, uplinks[1].ipv6Sources)
+	require.Equal(t, 64, uplinks[1].ipv6Prefix)
+	require.Equal(t, 56, uplinks[2].ipv6Prefix)
+	require.Equal(t, time.Second, uplinks[0].detectTime)
+
+	ipv4 := PublicIP{Source: []SourceConfig{{Type: sourceSTUN}, {Type: sourceHTTP}}}
+	require.Equal(t, 2*defaultUDPSourceTimeout+time.Second, maxDetectTime(&ipv4, time.Second))
+	require.Equal(t, uplinks[2], findUplink(uplinks, "wan2"))
+	require.Nil(t, findUplink(uplinks, "wan3"))
+
+	cfg.Uplink[1].Name = "wan1"
+	_, err = newUplinks(cfg, time.Second, o)
+	require.EqualError(t, err, "uplink wan1 is already exists")
+
+	cfg.Uplink[1].Name = ""
+	_, err = newUplinks(cfg, time.Second, o)
+	require.EqualError(t, err, "empty uplink name")
+
+	cfg.Uplink[1].Name = "wan2"
+	cfg.Uplink[1].PublicIPv6.Enabled = false
+	_, err = newUplinks(cfg, time.Second, o)
+	require.EqualError(t, err, "IPv4/IPv6 of uplink wan2 are all disabled")
+}
+
+func TestUpdater_Update_Uplink(t *testing.T) {
+	cfg := testProviderConfig(t, "http://provider.example.com")
+	cfg.Uplink = []Uplink{
+		{Name: "wan1"},
+		{Name: "wan2"},
+	}
+	cfg.Uplink[0].PublicIPv4.Enabled = true
+	cfg.Uplink[0].PublicIPv4.URL = "http://wan1.example.com/"
+	cfg.Uplink[1].PublicIPv4.Enabled = true
+	cfg.Uplink[1].PublicIPv4.URL = "http://wan2.example.com/"
+	cfg.Provider.Item = nil
+	cfg.Provider.Instance = []ProviderInstance{
+		{
+			Name:     "office1",
+			Template: "test",
+			Args:     map[string]string{"domain": "wan1.example.com"},
+			Uplink:   "wan1",
+		},
+		{
+			Name:     "office2",
+			Template: "test",
+			Args:     map[string]string{"domain": "wan2.example.com"},
+			Uplink:   "wan2",
+		},
+	}
+
+	var wan2Err error
+	publicIP := testRoundTripper(func(req *http.Request) (string, error) {
+		if req.URL.Host == "wan1.example.com" {
+			return "1.2.3.4", nil
+		}
+		return "5.6.7.8", wan2Err
+	})
+	var (
+		pushed []string
+		mutex  sync.Mutex
+	)
+	provider := testRoundTripper(func(req *http.Request) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pushed = append(pushed, req.URL.String())
+		return "good", nil
+	})
+	logger := new(testLogger)
+
+	updater, err := NewUpdater(cfg,
+		WithSourceTransport(publicIP), WithProviderTransport(provider),
+		WithClock(newTestClock()), WithLogger(logger),
+	)
+	require.NoError(t, err)
+	defer updater.Stop()
+	events := updater.Subscribe()
+
+	t.Run("common", func(t *testing.T) {
+		report, err := updater.UpdateContext(context.Background())
+		require.NoError(t, err)
+
+		sort.Strings(pushed)
+		expected := []string{
+			"http://provider.example.com/update?ip=1.2.3.4",
+			"http://provider.example.com/update?ip=5.6.7.8",
+		}
+		require.Equal(t, expected, pushed)
+		require.Nil(t, report.IPv4)
+		require.Len(t, report.Uplinks, 2)
+		require.Equal(t, "wan1", report.Uplinks[0].Name)
+		require.Equal(t, "1.2.3.4", report.Uplinks[0].IPv4.IP)
+		require.Equal(t, "5.6.7.8", report.Uplinks[1].IPv4.IP)
+		require.Equal(t, "wan1", report.Records[0].Uplink)
+		require.Equal(t, "1.2.3.4", report.Records[0].IP)
+		require.Equal(t, "wan2", report.Records[1].Uplink)
+		require.Equal(t, "5.6.7.8", report.Records[1].IP)
+
+		str := report.String()
+		require.Contains(t, str, "wan1 ipv4: 1.2.3.4 (http://wan1.example.com/ in 0s)\n")
+		require.Contains(t, str, "office2 wan2.example.com via wan2 ipv4 5.6.7.8: updated in 0s\n")
+
+		event := <-events
+		require.Equal(t, EventIPDetected, event.Type)
+		require.Equal(t, "wan1", event.Uplink)
+		require.Equal(t, "1.2.3.4", event.IP)
+	})
+
+	t.Run("uplink failed", func(t *testing.T) {
+		pushed = nil
+		wan2Err = errors.New("connection refused")
+		defer func() { wan2Err = nil }()
+
+		report, err := updater.UpdateContext(context.Background())
+		require.EqualError(t, err, "1 of 2 records failed to update")
+		require.Equal(t, []string{"http://provider.example.com/update?ip=1.2.3.4"}, pushed)
+		require.Contains(t, report.Uplinks[1].IPv4.Error, "connection refused")
+
+		record := report.Records[1]
+		require.Equal(t, "office2", record.Provider)
+		require.Equal(t, ResultFailed, record.Result)
+		require.Empty(t, record.IP)
+		require.Contains(t, record.Error, "failed to get public ipv4 address of uplink wan2")
+		require.Contains(t, report.String(), "office2 wan2.example.com via wan2 ipv4: failed in 0s")
+	})
+
+	t.Run("default uplink failed", func(t *testing.T) {
+		c := *cfg
+		c.PublicIPv4.Enabled = true
+		c.PublicIPv4.URL = "http://default.example.com/"
+		c.Provider.Instance = append([]ProviderInstance{{
+			Name:     "home",
+			Template: "test",
+			Args:     map[string]string{"domain": "home.example.com"},
+		}}, cfg.Provider.Instance...)
+		source := testRoundTripper(func(req *http.Request) (string, error) {
+			if req.URL.Host == "default.example.com" {
+				return "", errors.New("network is unreachable")
+			}
+			return publicIP(req)
+		})
+		updater, err := NewUpdater(&c,
+			WithSourceTransport(source), WithProviderTransport(provider),
+			WithClock(newTestClock()), WithLogger(logger),
+		)
+		require.NoError(t, err)
+		defer updater.Stop()
+		pushed = nil
+
+		report, err := updater.UpdateContext(context.Background())
+		require.ErrorContains(t, err, "1 of 3 records failed to update: failed to get public ipv4 address")
+		sort.Strings(pushed)
+		expected := []string{
+			"http://provider.example.com/update?ip=1.2.3.4",
+			"http://provider.example.com/update?ip=5.6.7.8",
+		}
+		require.Equal(t, expected, pushed)
+		require.Contains(t, report.IPv4.Error, "network is unreachable")
+		require.Equal(t, "home", report.Records[0].Provider)
+		require.Equal(t, ResultFailed, report.Records[0].Result)
+		require.Equal(t, "5.6.7.8", report.Uplinks[1].IPv4.IP)
+	})
+
+	t.Run("unknown uplink", func(t *testing.T) {
+		cfg.Provider.Instance[1].Uplink = "wan3"
+		defer func() { cfg.Provider.Instance[1].Uplink = "wan2" }()
+
+		_, err := NewUpdater(cfg, WithLogger(logger))
+		require.EqualError(t, err, "unknown uplink wan3 of provider instance office2")
+	})
+}
@@ -0,0 +1,795 @@
+package ddns
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ValidationError is a problem about the field in configuration file.
+type ValidationError struct {
+	File    string
+	Line    int
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Field == "" {
+		return pos + ": " + e.Message
+	}
+	return pos + ": " + e.Field + ": " + e.Message
+}
+
+// ValidationErrors contains all problems found in configuration files.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	s := make([]string, len(errs))
+	for i := 0; i < len(errs); i++ {
+		s[i] = errs[i].Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+var validMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
+// ValidateConfig is used to check the configuration file and the provider
+// files referenced by it, it will report all problems at once, if there
+// is no problem, it will return nil, otherwise return ValidationErrors.
+// The relative paths are resolved like LoadConfig, and the environment
+// variables are applied like the service before check.
+func ValidateConfig(path string) error {
+	v := newValidator(path)
+	loader := newConfigLoader()
+	err := loader.Load(path)
+	if err != nil {
+		v.add("", "failed to read config file: %s", err)
+		return v.errs
+	}
+	if len(loader.errs) != 0 {
+		return loader.errs
+	}
+	cfg, err := loader.Config()
+	if err != nil {
+		v.add("", "%s", err)
+		return v.errs
+	}
+	cfg.resolvePaths(filepath.Dir(path))
+	err = cfg.ApplyEnv()
+	if err != nil {
+		v.add("", "%s", err)
+		return v.errs
+	}
+	v.lines = loader.lines
+	v.checkConfig(cfg)
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// position is the location about a key in configuration files.
+type position struct {
+	file string
+	line int
+}
+
+type validator struct {
+	file  string
+	lines map[string]position
+	errs  ValidationErrors
+}
+
+func newValidator(file string) *validator {
+	return &validator{file: file}
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	pos := v.position(field)
+	err := ValidationError{
+		File:    pos.file,
+		Line:    pos.line,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+	v.errs = append(v.errs, &err)
+}
+
+// position returns the position about the field, if the field is not
+// found, it will try to return the position about the parent table.
+func (v *validator) position(field string) position {
+	pos, ok := lookupPosition(v.lines, field)
+	if !ok {
+		return position{file: v.file}
+	}
+	return pos
+}
+
+func (v *validator) addDecodeError(err error) {
+	switch e := err.(type) {
+	case *toml.StrictMissingError:
+		for i := 0; i < len(e.Errors); i++ {
+			de := &e.Errors[i]
+			row, _ := de.Position()
+			field := strings.Join(de.Key(), ".")
+			v.errs = append(v.errs, &ValidationError{
+				File:    v.file,
+				Line:    row,
+				Field:   field,
+				Message: "unknown field",
+			})
+		}
+	case *toml.DecodeError:
+		row, _ := e.Position()
+		v.errs = append(v.errs, &ValidationError{
+			File:    v.file,
+			Line:    row,
+			Field:   strings.Join(e.Key(), "."),
+			Message: e.Error(),
+		})
+	default:
+		v.add("", "%s", err)
+	}
+}
+
+func (v *validator) checkConfig(cfg *Config) {
+	if cfg.Period < 0 {
+		v.add("period", "must not be negative")
+	}
+	if cfg.Timeout < 0 {
+		v.add("timeout", "must not be negative")
+	}
+	v.checkLog(cfg)
+	if !cfg.PublicIPv4.Enabled && !cfg.PublicIPv6.Enabled && len(cfg.Uplink) == 0 {
+		v.add("", "IPv4/IPv6 are all disabled")
+	}
+	if cfg.PublicIPv4.Enabled {
+		v.checkPublicIP("public_ipv4", &cfg.PublicIPv4, true)
+		if cfg.PublicIPv4.CGNAT == cgnatIPv6Only && !cfg.PublicIPv6.Enabled {
+			v.add("public_ipv4.cgnat", "ipv6 only fallback requires IPv6 is enabled")
+		}
+	}
+	if cfg.PublicIPv6.Enabled {
+		v.checkPublicIP("public_ipv6", &cfg.PublicIPv6, false)
+	}
+	v.checkUplinks(cfg)
+	v.checkResolvers("verify.resolvers", cfg.Verify.Resolvers)
+	if cfg.Verify.Timeout < 0 {
+		v.add("verify.timeout", "must not be negative")
+	}
+	if cfg.Verify.Interval < 0 {
+		v.add("verify.interval", "must not be negative")
+	}
+	v.checkResolvers("check.resolvers", cfg.Check.Resolvers)
+	v.checkProxyURL("provider.proxy", cfg.Provider.ProxyURL)
+	v.checkProviders(cfg)
+}
+
+func (v *validator) checkUplinks(cfg *Config) {
+	names := make(map[string]bool, len(cfg.Uplink))
+	for i := 0; i < len(cfg.Uplink); i++ {
+		uplink := &cfg.Uplink[i]
+		n := len(v.errs)
+		switch {
+		case uplink.Name == "":
+			v.add("uplink.name", "empty uplink name")
+		case names[uplink.Name]:
+			v.add("uplink.name", "uplink is already exists")
+		}
+		names[uplink.Name] = true
+		if !uplink.PublicIPv4.Enabled && !uplink.PublicIPv6.Enabled {
+			v.add("uplink", "IPv4/IPv6 are all disabled")
+		}
+		if uplink.PublicIPv4.Enabled {
+			v.checkPublicIP("uplink.public_ipv4", &uplink.PublicIPv4, true)
+			if uplink.PublicIPv4.CGNAT == cgnatIPv6Only && !uplink.PublicIPv6.Enabled {
+				v.add("uplink.public_ipv4.cgnat", "ipv6 only fallback requires IPv6 is enabled")
+			}
+		}
+		if uplink.PublicIPv6.Enabled {
+			v.checkPublicIP("uplink.public_ipv6", &uplink.PublicIPv6, false)
+		}
+		for _, err := range v.errs[n:] {
+			err.Message += fmt.Sprintf(" (uplink %d)", i+1)
+		}
+	}
+}
+
+func hasUplink(cfg *Config, name string) bool {
+	for _, uplink := range cfg.Uplink {
+		if uplink.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) checkPublicIP(section string, cfg *PublicIP, ipv4 bool) {
+	switch {
+	case cfg.PrefixLen == 0:
+	case ipv4:
+		v.add(section+".prefix_len", "prefix length is only used with IPv6")
+	case cfg.PrefixLen < 0 || cfg.PrefixLen > 127:
+		v.add(section+".prefix_len", "invalid prefix length: %d", cfg.PrefixLen)
+	}
+	switch {
+	case cfg.Policy == "":
+	case ipv4:
+		v.add(section+".policy", "policy is only used with IPv6")
+	case cfg.Policy != policyStable:
+		v.add(section+".policy", "unsupported policy: \"%s\"", cfg.Policy)
+	}
+	switch {
+	case cfg.CGNAT == "":
+	case !ipv4:
+		v.add(section+".cgnat", "cgnat is only used with IPv4")
+	case cfg.CGNAT != cgnatIPv6Only:
+		v.add(section+".cgnat", "unsupported cgnat action: \"%s\"", cfg.CGNAT)
+	}
+	if len(cfg.Source) == 0 {
+		v.checkSourceURL(section+".url", cfg.URL)
+		v.checkProxyURL(section+".proxy", cfg.ProxyURL)
+		v.checkLocalAddr(section+".laddr", cfg.LocalAddr, ipv4)
+		return
+	}
+	for i := 0; i < len(cfg.Source); i++ {
+		n := len(v.errs)
+		v.checkSource(section+".source", &cfg.Source[i], ipv4)
+		for _, err := range v.errs[n:] {
+			err.Message += fmt.Sprintf(" (source %d)", i+1)
+		}
+	}
+}
+
+func (v *validator) checkSource(prefix string, src *SourceConfig, ipv4 bool) {
+	switch src.Type {
+	case "", sourceHTTP:
+		v.checkSourceURL(prefix+".url", src.URL)
+		v.checkProxyURL(prefix+".proxy", src.ProxyURL)
+		v.checkLocalAddr(prefix+".laddr", src.LocalAddr, ipv4)
+		v.checkSourceFormat(prefix, src)
+	case sourceExec:
+		if len(src.Command) == 0 || src.Command[0] == "" {
+			v.add(prefix+".command", "empty command")
+		}
+		if src.Dir != "" {
+			stat, err := os.Stat(src.Dir)
+			if err != nil {
+				v.add(prefix+".dir", "%s", err)
+			} else if !stat.IsDir() {
+				v.add(prefix+".dir", "\"%s\" is not a directory", src.Dir)
+			}
+		}
+		for _, env := range src.Env {
+			if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+				v.add(prefix+".env", "invalid environment variable: \"%s\"", env)
+			}
+		}
+		v.checkSourceFormat(prefix, src)
+		v.checkNoInterface(prefix, src)
+	case sourceInterface:
+		if src.Interface == "" {
+			v.add(prefix+".interface", "empty interface")
+		}
+	case sourceUPnP, sourceNATPMP, sourcePCP:
+		if !ipv4 {
+			v.add(prefix+".type", "%s source only supports IPv4", src.Type)
+		}
+		v.checkNoInterface(prefix, src)
+		if src.Gateway == "" {
+			break
+		}
+		if src.Type == sourceUPnP {
+			v.checkSourceURL(prefix+".gateway", src.Gateway)
+			break
+		}
+		v.checkPort(prefix+".gateway", src.Gateway)
+	case sourceSTUN:
+		for _, server := range src.Servers {
+			v.checkPort(prefix+".servers", server)
+		}
+		v.checkLocalAddr(prefix+".laddr", src.LocalAddr, ipv4)
+	case sourceDNS:
+		v.checkPort(prefix+".server", src.Server)
+		if src.Record != "" {
+			_, err := parseDNSSourceType(src.Record, ipv4)
+			if err != nil {
+				v.add(prefix+".record", "%s", err)
+			}
+		}
+	default:
+		v.add(prefix+".type", "unsupported source type: \"%s\"", src.Type)
+	}
+	if src.Timeout < 0 {
+		v.add(prefix+".timeout", "must not be negative")
+	}
+	if src.Cache < 0 {
+		v.add(prefix+".cache", "must not be negative")
+	}
+}
+
+func (v *validator) checkSourceFormat(prefix string, src *SourceConfig) {
+	switch src.Format {
+	case "", formatText:
+		if src.Key != "" {
+			v.add(prefix+".key", "key is only used with json format")
+		}
+	case formatJSON:
+	default:
+		v.add(prefix+".format", "unsupported format: \"%s\"", src.Format)
+	}
+	if src.Regexp != "" {
+		_, err := regexp.Compile(src.Regexp)
+		if err != nil {
+			v.add(prefix+".regexp", "%s", err)
+		}
+	}
+}
+
+// checkPort is used to check the port if the address has it.
+func (v *validator) checkPort(field, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	_, err = strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		v.add(field, "invalid port: \"%s\"", port)
+	}
+}
+
+func (v *validator) checkLog(cfg *Config) {
+	if cfg.LogSyslog != "" && cfg.LogSyslog != "local" {
+		URL, err := url.Parse(cfg.LogSyslog)
+		if err != nil {
+			v.add("log_syslog", "invalid address: %s", err)
+		} else {
+			switch URL.Scheme {
+			case "unix", "unixgram":
+				if URL.Path == "" {
+					v.add("log_syslog", "empty socket path")
+				}
+			case "udp", "tcp", "tls":
+				if URL.Host == "" {
+					v.add("log_syslog", "empty host")
+				}
+			default:
+				v.add("log_syslog", "unsupported network: \"%s\"", URL.Scheme)
+			}
+		}
+	}
+	if cfg.LogRotate.MaxSize < 0 {
+		v.add("log_rotate.max_size", "must not be negative")
+	}
+	if cfg.LogRotate.MaxAge < 0 {
+		v.add("log_rotate.max_age", "must not be negative")
+	}
+	if cfg.LogRotate.MaxBackups < 0 {
+		v.add("log_rotate.max_backups", "must not be negative")
+	}
+}
+
+func (v *validator) checkSourceURL(field, URL string) {
+	if URL == "" {
+		v.add(field, "empty url")
+		return
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		v.add(field, "invalid url: %s", err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.add(field, "unsupported url scheme: \"%s\"", u.Scheme)
+	}
+	if u.Host == "" {
+		v.add(field, "empty host in url")
+	}
+}
+
+func (v *validator) checkProxyURL(field, URL string) {
+	if URL == "" {
+		return
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		v.add(field, "invalid proxy url: %s", err)
+		return
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		v.add(field, "unsupported proxy scheme: \"%s\"", u.Scheme)
+	}
+	if u.Host == "" {
+		v.add(field, "empty host in proxy url")
+	}
+}
+
+// checkNoInterface is used to check the interface is not set to the
+// source that can not be bound to the interface.
+func (v *validator) checkNoInterface(prefix string, src *SourceConfig) {
+	if src.Interface != "" {
+		v.add(prefix+".interface", "interface is not used by %s source", src.Type)
+	}
+}
+
+func (v *validator) checkLocalAddr(field, addr string, ipv4 bool) {
+	if addr == "" {
+		return
+	}
+	host := addr
+	if net.ParseIP(addr) == nil {
+		h, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			v.add(field, "invalid local address: %s", err)
+			return
+		}
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return
+	}
+	if ipv4 && ip.To4() == nil {
+		v.add(field, "\"%s\" is not an IPv4 address", host)
+	}
+	if !ipv4 && ip.To4() != nil {
+		v.add(field, "\"%s\" is not an IPv6 address", host)
+	}
+}
+
+func (v *validator) checkResolvers(field string, resolvers []string) {
+	for _, resolver := range resolvers {
+		host, _, err := net.SplitHostPort(dnsServerAddress(resolver))
+		if err != nil || net.ParseIP(host) == nil {
+			v.add(field, "invalid resolver address: \"%s\"", resolver)
+		}
+	}
+}
+
+func (v *validator) checkProviders(cfg *Config) {
+	dir := cfg.Provider.Dir
+	if dir != "" {
+		stat, err := os.Stat(dir)
+		if err != nil {
+			v.add("provider.dir", "%s", err)
+			return
+		}
+		if !stat.IsDir() {
+			v.add("provider.dir", "\"%s\" is not a directory", dir)
+			return
+		}
+	}
+	items, err := cfg.providerItems()
+	if err != nil {
+		v.add("provider.glob", "%s", err)
+	}
+	if len(items) == 0 && len(cfg.Provider.Instance) == 0 {
+		v.add("provider.item", "empty provider")
+	}
+	for _, item := range items {
+		path := filepath.Join(dir, item) + ".toml"
+		_, err := os.Stat(path)
+		if err != nil {
+			v.add("provider.item", "%s", err)
+			continue
+		}
+		pv := newValidator(path)
+		pv.checkProvider(cfg, nil)
+		v.errs = append(v.errs, pv.errs...)
+	}
+	// the instance name is case-insensitive like environment variables
+	names := make(map[string]bool, len(cfg.Provider.Instance))
+	for _, instance := range cfg.Provider.Instance {
+		name := strings.ToLower(instance.Name)
+		switch {
+		case instance.Name == "":
+			v.add("provider.instance.name", "empty provider instance name")
+		case names[name]:
+			v.add("provider.instance.name", "provider instance is already exists (instance %s)", instance.Name)
+		}
+		names[name] = true
+		_, err := parseInterfaceID(instance.IPv6Suffix, instance.IPv6MAC)
+		if err != nil {
+			field := "provider.instance.ipv6_suffix"
+			if instance.IPv6Suffix == "" {
+				field = "provider.instance.ipv6_mac"
+			}
+			v.add(field, "%s (instance %s)", err, instance.Name)
+		}
+		if instance.Uplink != "" && !hasUplink(cfg, instance.Uplink) {
+			v.add("provider.instance.uplink", "unknown uplink \"%s\" (instance %s)", instance.Uplink, instance.Name)
+		}
+		if instance.Template == "" {
+			v.add("provider.instance.template", "empty template of %s", instance.Name)
+			continue
+		}
+		path := filepath.Join(dir, instance.Template) + ".toml"
+		_, err = os.Stat(path)
+		if err != nil {
+			v.add("provider.instance.template", "%s", err)
+			continue
+		}
+		pv := newValidator(path)
+		pv.checkProvider(cfg, instance.Args)
+		for _, err := range pv.errs {
+			err.Message += " (instance " + instance.Name + ")"
+		}
+		v.errs = append(v.errs, pv.errs...)
+	}
+}
+
+// checkProvider is used to check the provider file, the args will
+// override the arguments in it like the provider instance.
+func (v *validator) checkProvider(cfg *Config, args map[string]string) {
+	data, err := os.ReadFile(v.file)
+	if err != nil {
+		v.add("", "failed to read provider file: %s", err)
+		return
+	}
+	v.lines = keyPositions(v.file, data)
+	pc := new(provCfg)
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(pc)
+	if err != nil {
+		v.addDecodeError(err)
+		return
+	}
+	if len(args) != 0 && pc.Args == nil {
+		pc.Args = make(map[string]string, len(args))
+	}
+	for k, val := range args {
+		pc.Args[k] = val
+	}
+	// check meta
+	if pc.Meta.Host == "" {
+		v.add("meta.host_url", "empty host url")
+	} else {
+		host, ok := v.checkTemplate("meta.host_url", pc.Meta.Host, pc.Args, "")
+		if ok {
+			u, err := url.Parse(host)
+			switch {
+			case err != nil:
+				v.add("meta.host_url", "invalid url: %s", err)
+			case u.Scheme != "http" && u.Scheme != "https":
+				v.add("meta.host_url", "unsupported url scheme: \"%s\"", u.Scheme)
+			case u.Host == "":
+				v.add("meta.host_url", "empty host in url")
+			}
+		}
+	}
+	v.checkMethod("meta.method", pc.Meta.Method)
+	if pc.Meta.Response == "" {
+		v.add("meta.response", "empty response")
+	}
+	if pc.Meta.Domain != "" {
+		v.checkTemplate("meta.domain", pc.Meta.Domain, pc.Args, "")
+	} else if cfg.Verify.Enabled {
+		v.add("meta.domain", "domain is required for verify")
+	}
+	// check path and body about IPv4/IPv6
+	if pc.IPv4.Path == "" && pc.IPv6.Path == "" {
+		v.add("", "IPv4/IPv6 url path are all empty")
+	}
+	if pc.IPv4.Path != "" {
+		v.checkTemplate("ipv4.path", pc.IPv4.Path, pc.Args, "ipv4")
+	}
+	if pc.IPv4.Body != "" {
+		v.checkTemplate("ipv4.body", pc.IPv4.Body, pc.Args, "ipv4")
+	}
+	if pc.IPv6.Path != "" {
+		v.checkTemplate("ipv6.path", pc.IPv6.Path, pc.Args, "ipv6")
+	}
+	if pc.IPv6.Body != "" {
+		v.checkTemplate("ipv6.body", pc.IPv6.Body, pc.Args, "ipv6")
+	}
+	// check the request about get current value
+	if pc.Current.Path != "" {
+		v.checkMethod("current.method", pc.Current.Method)
+		v.checkTemplate("current.path", pc.Current.Path, pc.Args, "")
+	}
+	if pc.Current.Body != "" {
+		v.checkTemplate("current.body", pc.Current.Body, pc.Args, "")
+	}
+}
+
+func (v *validator) checkMethod(field, method string) {
+	if method == "" {
+		return
+	}
+	if !validMethods[method] {
+		v.add(field, "invalid http method: \"%s\"", method)
+	}
+}
+
+// checkTemplate is used to parse the template and check the referenced
+// arguments are exist, the family is "ipv4", "ipv6" or empty, it will be
+// provided by updater. It returns the executed template if it is valid.
+func (v *validator) checkTemplate(field, text string, args map[string]string, family string) (string, bool) {
+	tmpl, err := template.New(field).Parse(text)
+	if err != nil {
+		v.add(field, "invalid template: %s", err)
+		return "", false
+	}
+	ok := true
+	for _, name := range templateFields(tmpl) {
+		if name == family {
+			continue
+		}
+		if name == "ipv4" || name == "ipv6" {
+			if family == "" {
+				v.add(field, "\"%s\" is not available here", name)
+			} else {
+				v.add(field, "\"%s\" is used in %s section", name, family)
+			}
+			ok = false
+			continue
+		}
+		_, exist := args[name]
+		if !exist {
+			v.add(field, "argument \"%s\" is not defined in args", name)
+			ok = false
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	data := make(map[string]string, len(args)+1)
+	for k, val := range args {
+		data[k] = val
+	}
+	if family != "" {
+		data[family] = ""
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(text)))
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		v.add(field, "failed to execute template: %s", err)
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// templateFields returns the field names like {{.name}} in the template.
+func templateFields(tmpl *template.Template) []string {
+	var (
+		names []string
+		walk  func(node parse.Node)
+	)
+	seen := make(map[string]bool)
+	walkPipe := func(pipe *parse.PipeNode) {
+		if pipe == nil {
+			return
+		}
+		for _, cmd := range pipe.Cmds {
+			for _, arg := range cmd.Args {
+				walk(arg)
+			}
+		}
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, node := range n.Nodes {
+				walk(node)
+			}
+		case *parse.ActionNode:
+			walkPipe(n.Pipe)
+		case *parse.PipeNode:
+			walkPipe(n)
+		case *parse.IfNode:
+			walkPipe(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walkPipe(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walkPipe(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.FieldNode:
+			name := n.Ident[0]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if tmpl.Tree != nil {
+		walk(tmpl.Tree.Root)
+	}
+	return names
+}
+
+// keyPositions is used to find the position about each key path in file.
+func keyPositions(file string, data []byte) map[string]position {
+	lines := tomlKeyLines(data)
+	positions := make(map[string]position, len(lines))
+	for key, line := range lines {
+		positions[key] = position{file: file, line: line}
+	}
+	return positions
+}
+
+// lookupPosition is used to find the position about the field, if the field
+// is not found, it will try to find the position about the parent table.
+func lookupPosition(positions map[string]position, field string) (position, bool) {
+	for field != "" {
+		pos, ok := positions[field]
+		if ok {
+			return pos, true
+		}
+		i := strings.LastIndex(field, ".")
+		if i == -1 {
+			break
+		}
+		field = field[:i]
+	}
+	return position{}, false
+}
+
+// tomlKeyLines is used to find the line number about each key path like
+// "public_ipv4.url", it only supports the simple format about tables.
+func tomlKeyLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '[':
+			end := strings.Index(line, "]")
+			if end == -1 {
+				continue
+			}
+			table = strings.Trim(line[:end], "[] \t")
+			if _, ok := lines[table]; !ok {
+				lines[table] = i + 1
+			}
+		default:
+			eq := strings.Index(line, "=")
+			if eq == -1 {
+				continue
+			}
+			key := strings.Trim(strings.TrimSpace(line[:eq]), "\"'")
+			if table != "" {
+				key = table + "." + key
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = i + 1
+			}
+		}
+	}
+	return lines
+}
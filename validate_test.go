@@ -0,0 +1,412 @@
+package ddns
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `
+[public_ipv4]
+  enabled = true
+  url     = "https://api.ipify.org/"
+  laddr   = "127.0.0.1"
+
+[provider]
+  dir  = "%s"
+  item = ["noip"]
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		providerDir = filepath.ToSlash(providerDir)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", providerDir, 1))
+
+		err = ValidateConfig(path)
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `period = "-1m"
+log_syslog = "foo://127.0.0.1"
+
+[public_ipv4]
+  enabled = true
+  url     = "ftp://127.0.0.1/"
+  proxy   = "foo://127.0.0.1/"
+  laddr   = "::1"
+
+[public_ipv6]
+  enabled = true
+  url     = ""
+  laddr   = "127.0.0.1:0"
+
+[verify]
+  enabled   = true
+  resolvers = ["foo"]
+
+[provider]
+  dir  = "%s"
+  item = ["invalid", "missing"]
+`
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(dir), 1))
+		provider := `[meta]
+  host_url = "ftp://{{.user}}@example.com"
+  method   = "FETCH"
+
+[ipv4]
+  path = "/update?ip={{.ipv6}}"
+
+[ipv6]
+  path = "/update?ip={{.ipv6"
+
+[current]
+  path = "/current/{{.ipv4}}"
+
+[args]
+  user = "user"
+`
+		providerPath := testWriteFile(t, dir, "invalid.toml", provider)
+
+		err := ValidateConfig(path)
+		require.Error(t, err)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+
+		expected := []string{
+			path + ":1: period: must not be negative",
+			path + ":2: log_syslog: unsupported network: \"foo\"",
+			path + ":6: public_ipv4.url: unsupported url scheme: \"ftp\"",
+			path + ":7: public_ipv4.proxy: unsupported proxy scheme: \"foo\"",
+			path + ":8: public_ipv4.laddr: \"::1\" is not an IPv4 address",
+			path + ":12: public_ipv6.url: empty url",
+			path + ":13: public_ipv6.laddr: \"127.0.0.1\" is not an IPv6 address",
+			path + ":17: verify.resolvers: invalid resolver address: \"foo\"",
+			providerPath + ":2: meta.host_url: unsupported url scheme: \"ftp\"",
+			providerPath + ":3: meta.method: invalid http method: \"FETCH\"",
+			providerPath + ":1: meta.response: empty response",
+			providerPath + ":1: meta.domain: domain is required for verify",
+			providerPath + ":6: ipv4.path: \"ipv6\" is used in ipv4 section",
+			providerPath + ":9: ipv6.path: invalid template: template: ipv6.path:1: unclosed action",
+			providerPath + ":12: current.path: \"ipv4\" is not available here",
+		}
+		require.Len(t, errs, len(expected)+1)
+		for i := 0; i < len(expected); i++ {
+			require.Equal(t, expected[i], errs[i].Error())
+		}
+		last := errs[len(errs)-1].Error()
+		require.True(t, strings.HasPrefix(last, path+":21: provider.item: "), last)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		dir := t.TempDir()
+		path := testWriteFile(t, dir, "config.toml", "foo = 1\n\n[public_ipv4]\n  bar = 2\n")
+
+		err := ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 2)
+		require.Equal(t, path+":1: foo: unknown field", errs[0].Error())
+		require.Equal(t, path+":4: public_ipv4.bar: unknown field", errs[1].Error())
+	})
+
+	t.Run("missing argument", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := "[public_ipv4]\n  enabled = true\n  url = \"http://127.0.0.1/\"\n\n" +
+			"[provider]\n  dir = \"" + filepath.ToSlash(dir) + "\"\n  item = [\"test\"]\n"
+		path := testWriteFile(t, dir, "config.toml", cfg)
+		provider := "[meta]\n  host_url = \"https://example.com\"\n  response = \"good\"\n" +
+			"  domain = \"{{.hostname}}\"\n\n[ipv4]\n  body = \"{{.token}}\"\n"
+		providerPath := testWriteFile(t, dir, "test.toml", provider)
+
+		err := ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		expected := []string{
+			providerPath + ":4: meta.domain: argument \"hostname\" is not defined in args",
+			providerPath + ": IPv4/IPv6 url path are all empty",
+			providerPath + ":7: ipv4.body: argument \"token\" is not defined in args",
+		}
+		require.Len(t, errs, len(expected))
+		for i := 0; i < len(expected); i++ {
+			require.Equal(t, expected[i], errs[i].Error())
+		}
+	})
+
+	t.Run("source list", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[public_ipv4]
+  enabled = true
+
+[[public_ipv4.source]]
+  type    = "upnp"
+  gateway = "ftp://192.168.1.1/"
+
+[[public_ipv4.source]]
+  type    = "natpmp"
+  gateway = "192.168.1.1:foo"
+  timeout = "-1s"
+
+[[public_ipv4.source]]
+  type = "http"
+  url  = "https://api.ipify.org/"
+
+[[public_ipv4.source]]
+  type    = "exec"
+  command = []
+  format  = "xml"
+  regexp  = "("
+  env     = ["FOO"]
+
+[public_ipv6]
+  enabled    = true
+  prefix_len = 128
+  policy     = "temporary"
+
+[[public_ipv6.source]]
+  type = "pcp"
+
+[[public_ipv6.source]]
+  type = "foo"
+
+[[public_ipv6.source]]
+  type   = "dns"
+  record = "A"
+
+[[public_ipv6.source]]
+  type = "interface"
+
+[provider]
+  dir  = "%s"
+  item = ["noip"]
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		expected := []string{
+			path + ":6: public_ipv4.source.gateway: unsupported url scheme: \"ftp\" (source 1)",
+			path + ":6: public_ipv4.source.gateway: invalid port: \"foo\" (source 2)",
+			path + ":11: public_ipv4.source.timeout: must not be negative (source 2)",
+			path + ":19: public_ipv4.source.command: empty command (source 4)",
+			path + ":22: public_ipv4.source.env: invalid environment variable: \"FOO\" (source 4)",
+			path + ":20: public_ipv4.source.format: unsupported format: \"xml\" (source 4)",
+			path + ":21: public_ipv4.source.regexp: error parsing regexp: missing closing ): `(` (source 4)",
+			path + ":26: public_ipv6.prefix_len: invalid prefix length: 128",
+			path + ":27: public_ipv6.policy: unsupported policy: \"temporary\"",
+			path + ":30: public_ipv6.source.type: pcp source only supports IPv4 (source 1)",
+			path + ":30: public_ipv6.source.type: unsupported source type: \"foo\" (source 2)",
+			path + ":37: public_ipv6.source.record: A record can not be used for IPv6 (source 3)",
+			path + ":29: public_ipv6.source.interface: empty interface (source 4)",
+		}
+		require.Len(t, errs, len(expected))
+		for i := 0; i < len(expected); i++ {
+			require.Equal(t, expected[i], errs[i].Error())
+		}
+	})
+
+	t.Run("cgnat", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[public_ipv4]
+  enabled = true
+  url     = "https://api.ipify.org/"
+  cgnat   = "ipv6_only"
+
+[public_ipv6]
+  enabled = false
+  cgnat   = "ipv6_only"
+
+[provider]
+  dir  = "%s"
+  item = ["noip"]
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		require.Equal(t, path+":4: public_ipv4.cgnat: ipv6 only fallback requires IPv6 is enabled", errs[0].Error())
+
+		cfg = strings.Replace(cfg, "enabled = false", "enabled = true\n  url     = \"https://api6.ipify.org/\"", 1)
+		path = testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+		err = ValidateConfig(path)
+		errs, ok = err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		require.Equal(t, path+":9: public_ipv6.cgnat: cgnat is only used with IPv4", errs[0].Error())
+	})
+
+	t.Run("interface", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[public_ipv4]
+  enabled   = true
+  interface = "eth1"
+
+  [[public_ipv4.source]]
+    type      = "upnp"
+    interface = "eth1"
+
+  [[public_ipv4.source]]
+    type      = "http"
+    url       = "https://api.ipify.org/"
+    interface = "eth1"
+
+[provider]
+  dir       = "%s"
+  item      = ["noip"]
+  interface = "eth1"
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		require.Equal(t, path+":7: public_ipv4.source.interface: interface is not used by upnp source (source 1)", errs[0].Error())
+	})
+
+	t.Run("uplink", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[[uplink]]
+  name = "wan1"
+
+  [uplink.public_ipv4]
+    enabled = true
+    laddr   = "::1"
+
+[[uplink]]
+  name = "wan1"
+
+[provider]
+  dir  = "%s"
+
+[[provider.instance]]
+  name     = "office"
+  template = "noip"
+  uplink   = "wan2"
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		expected := []string{
+			path + ":4: uplink.public_ipv4.url: empty url (uplink 1)",
+			path + ":6: uplink.public_ipv4.laddr: \"::1\" is not an IPv4 address (uplink 1)",
+			path + ":2: uplink.name: uplink is already exists (uplink 2)",
+			path + ":1: uplink: IPv4/IPv6 are all disabled (uplink 2)",
+			path + ":17: provider.instance.uplink: unknown uplink \"wan2\" (instance office)",
+		}
+		require.Len(t, errs, len(expected))
+		for i := 0; i < len(expected); i++ {
+			require.Equal(t, expected[i], errs[i].Error())
+		}
+	})
+
+	t.Run("provider instance", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[public_ipv4]
+  enabled = true
+  url = "http://127.0.0.1/"
+
+[provider]
+  dir  = "%s"
+
+[[provider.instance]]
+  name     = "home"
+  template = "noip"
+
+[[provider.instance]]
+  name     = "Home"
+  template = "noip"
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		expected := path + ":9: provider.instance.name: provider instance is already exists (instance Home)"
+		require.Equal(t, expected, errs[0].Error())
+	})
+
+	t.Run("environment variables", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := `[public_ipv4]
+  enabled = true
+  url = "http://127.0.0.1/"
+
+[provider]
+  dir  = "%s"
+
+[[provider.instance]]
+  name     = "home"
+  template = "noip"
+`
+		providerDir, err := filepath.Abs("provider")
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", strings.Replace(cfg, "%s", filepath.ToSlash(providerDir), 1))
+
+		t.Setenv("DDNS_PROVIDER_INSTANCE_HOME_UPLINK", "wan1")
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		expected := path + ":8: provider.instance.uplink: unknown uplink \"wan1\" (instance home)"
+		require.Equal(t, expected, errs[0].Error())
+
+		t.Setenv("DDNS_PERIOD", "foo")
+
+		err = ValidateConfig(path)
+		errs, ok = err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 1)
+		require.Contains(t, errs[0].Error(), "DDNS_PERIOD")
+	})
+
+	t.Run("included file", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.Mkdir(filepath.Join(dir, "provider"), 0750)
+		require.NoError(t, err)
+		path := testWriteFile(t, dir, "config.toml", "include = [\"ipv4.toml\"]\n\n[provider]\n  glob = [\"*\"]\n")
+		ipv4 := testWriteFile(t, dir, "ipv4.toml", "[public_ipv4]\n  enabled = true\n  url = \"ftp://127.0.0.1/\"\n")
+
+		err = ValidateConfig(path)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		require.Len(t, errs, 2)
+		require.Equal(t, ipv4+":3: public_ipv4.url: unsupported url scheme: \"ftp\"", errs[0].Error())
+		require.Equal(t, path+":3: provider.item: empty provider", errs[1].Error())
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		err := ValidateConfig("testdata/foo.toml")
+		require.Error(t, err)
+	})
+}
+
+func testWriteFile(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(data), 0600)
+	require.NoError(t, err)
+	return path
+}
@@ -0,0 +1,156 @@
+package ddns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultVerifyTimeout  = 2 * time.Minute
+	defaultVerifyInterval = 5 * time.Second
+)
+
+// verifier is used to check the updated record is resolved to the new
+// address by the authoritative name servers or the configured resolvers.
+type verifier struct {
+	clock     Clock
+	client    *dnsClient
+	resolver  Resolver
+	resolvers []string
+	timeout   time.Duration
+	interval  time.Duration
+}
+
+func newVerifier(cfg *Config, clock Clock, resolver Resolver) *verifier {
+	timeout := time.Duration(cfg.Verify.Timeout)
+	if timeout == 0 {
+		timeout = defaultVerifyTimeout
+	}
+	interval := time.Duration(cfg.Verify.Interval)
+	if interval == 0 {
+		interval = defaultVerifyInterval
+	}
+	v := verifier{
+		clock:     clock,
+		client:    new(dnsClient),
+		resolver:  resolver,
+		resolvers: cfg.Verify.Resolvers,
+		timeout:   timeout,
+		interval:  interval,
+	}
+	return &v
+}
+
+// Verify will query the record until all name servers return the expected
+// address or timeout, it returns the propagation time.
+func (v *verifier) Verify(ctx context.Context, domain string, typ uint16, ip string) (time.Duration, error) {
+	expected := net.ParseIP(ip)
+	if expected == nil {
+		return 0, errors.Errorf("invalid ip address: \"%s\"", ip)
+	}
+	start := v.clock.Now()
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	recursion := len(v.resolvers) != 0
+	servers := v.resolvers
+	if !recursion {
+		var err error
+		servers, err = findNameservers(ctx, v.resolver, domain)
+		if err != nil {
+			return 0, err
+		}
+	}
+	pending := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		pending[server] = struct{}{}
+	}
+	var (
+		lastErr    error
+		mismatched bool
+	)
+	for {
+		for server := range pending {
+			values, err := v.lookup(ctx, server, domain, typ, recursion)
+			if err != nil {
+				// the mismatched record is more useful than the timeout
+				// that is usually caused by the deadline of verification
+				if !mismatched {
+					lastErr = errors.WithMessagef(err, "failed to query %s", server)
+				}
+				continue
+			}
+			if containsIP(values, expected) {
+				delete(pending, server)
+				continue
+			}
+			const format = "%s record of %s is %s at %s instead of %s"
+			got := strings.Join(values, ",")
+			if got == "" {
+				got = "empty"
+			}
+			lastErr = errors.Errorf(format, dnsTypeString(typ), domain, got, server, ip)
+			mismatched = true
+		}
+		if len(pending) == 0 {
+			return v.clock.Now().Sub(start), nil
+		}
+		select {
+		case <-v.clock.After(v.interval):
+		case <-ctx.Done():
+			return 0, errors.WithMessage(lastErr, "record is not propagated")
+		}
+	}
+}
+
+func (v *verifier) lookup(ctx context.Context, server, domain string, typ uint16, recursion bool) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.interval)
+	defer cancel()
+	return v.client.Lookup(ctx, server, domain, typ, recursion)
+}
+
+func containsIP(values []string, ip net.IP) bool {
+	for _, value := range values {
+		if ip.Equal(net.ParseIP(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyIP is used to verify all domains of the provider after push,
+// it returns the longest propagation time and the last error.
+func (updater *Updater) verifyIP(ctx context.Context, provider *provider, typ uint16, ip string) (time.Duration, error) {
+	if updater.verifier == nil || updater.dryRun {
+		return 0, nil
+	}
+	var (
+		propagation time.Duration
+		lastErr     error
+	)
+	for _, domain := range provider.Domains {
+		d, err := updater.verifier.Verify(ctx, domain, typ, ip)
+		updater.statusMu.Lock()
+		if err != nil {
+			updater.status.Mismatched++
+		} else {
+			updater.status.Propagated++
+			updater.status.Propagation = d
+		}
+		updater.statusMu.Unlock()
+		if err != nil {
+			updater.logger.Errorf("failed to verify %s record of %s: %s", dnsTypeString(typ), domain, err)
+			lastErr = errors.WithMessagef(err, "failed to verify %s", domain)
+			continue
+		}
+		const format = "%s record of %s is propagated in %s"
+		updater.logger.Infof(format, dnsTypeString(typ), domain, d.Round(time.Millisecond))
+		if d > propagation {
+			propagation = d
+		}
+	}
+	return propagation, lastErr
+}
@@ -0,0 +1,93 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	server := newTestDNSServer(t)
+	ctx := context.Background()
+
+	cfg := Config{}
+	cfg.Verify.Resolvers = []string{server.Addr()}
+	cfg.Verify.Timeout = Duration(3 * time.Second)
+	cfg.Verify.Interval = Duration(10 * time.Millisecond)
+
+	t.Run("propagated", func(t *testing.T) {
+		server.Set("test.example.com", dnsTypeA, "1.1.1.1")
+		timer := time.AfterFunc(100*time.Millisecond, func() {
+			server.Set("test.example.com", dnsTypeA, "1.2.3.4")
+		})
+		defer timer.Stop()
+
+		v := newVerifier(&cfg, realClock{}, net.DefaultResolver)
+		d, err := v.Verify(ctx, "test.example.com", dnsTypeA, "1.2.3.4")
+		require.NoError(t, err)
+		require.Greater(t, d, 50*time.Millisecond)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		server.Set("test.example.com", dnsTypeAAAA, "2400:cb00::1")
+
+		v := newVerifier(&cfg, realClock{}, net.DefaultResolver)
+		_, err := v.Verify(ctx, "test.example.com", dnsTypeAAAA, "2400:cb00:0::1")
+		require.NoError(t, err)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		server.Set("test.example.com", dnsTypeA, "1.1.1.1")
+		c := cfg
+		c.Verify.Timeout = Duration(100 * time.Millisecond)
+
+		v := newVerifier(&c, realClock{}, net.DefaultResolver)
+		_, err := v.Verify(ctx, "test.example.com", dnsTypeA, "1.2.3.4")
+		const errStr = "record is not propagated: A record of test.example.com is 1.1.1.1 at %s instead of 1.2.3.4"
+		require.EqualError(t, err, fmt.Sprintf(errStr, server.Addr()))
+	})
+
+	t.Run("invalid ip", func(t *testing.T) {
+		v := newVerifier(&cfg, realClock{}, net.DefaultResolver)
+		_, err := v.Verify(ctx, "test.example.com", dnsTypeA, "foo")
+		require.EqualError(t, err, "invalid ip address: \"foo\"")
+	})
+}
+
+func TestUpdater_Verify(t *testing.T) {
+	server := newTestDNSServer(t)
+	server.Set("test.example.com", dnsTypeA, "1.2.3.4")
+
+	ipServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("1.2.3.4"))
+	}))
+	defer ipServer.Close()
+	providerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("good"))
+	}))
+	defer providerServer.Close()
+
+	cfg := testProviderConfig(t, providerServer.URL)
+	cfg.PublicIPv4.Enabled = true
+	cfg.PublicIPv4.URL = ipServer.URL
+	cfg.Verify.Enabled = true
+	cfg.Verify.Resolvers = []string{server.Addr()}
+	cfg.Verify.Interval = Duration(10 * time.Millisecond)
+
+	updater, err := NewUpdater(cfg)
+	require.NoError(t, err)
+
+	updater.Update()
+	require.True(t, updater.status.Success)
+	require.Equal(t, 1, updater.status.Propagated)
+	require.Zero(t, updater.status.Mismatched)
+	require.Contains(t, updater.status.String(), "propagated: 1, mismatched: 0")
+
+	updater.Stop()
+}